@@ -0,0 +1,70 @@
+package main
+
+import (
+	"authentication/oidc"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// defaultAccessTokenJWKSURL is where the broker publishes the keys its access tokens
+// are signed with
+const defaultAccessTokenJWKSURL = "http://broker-svc:8888/.well-known/jwks.json"
+
+var errUnauthorized = errors.New("unauthorized")
+
+// Caller is the user an access token was issued to
+type Caller struct {
+	UserID string
+	Role   string
+}
+
+// callerFromRequest verifies the access token the broker passed on in the
+// Authorization header. A request without one has no caller; the user actions refuse
+// it. The user is never taken from the request body, so reaching the port doesn't let
+// a client act as somebody else.
+func (app *Config) callerFromRequest(r *http.Request) (Caller, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return Caller{}, nil
+	}
+
+	raw, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || raw == "" {
+		return Caller{}, errors.New("authorization header format is invalid")
+	}
+
+	return verifyAccessToken(r.Context(), app.AccessTokenKeys, raw)
+}
+
+// verifyAccessToken checks the token's signature against the broker's JWKS and its
+// expiry, and returns the caller it was issued to
+func verifyAccessToken(ctx context.Context, keys *oidc.RemoteKeySet, raw string) (Caller, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.Key(ctx, kid, token.Method.Alg())
+	})
+	if err != nil {
+		return Caller{}, fmt.Errorf("invalid access token: %w", err)
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Caller{}, errors.New("invalid access token: no expiry")
+	}
+
+	id, _ := claims["id"].(string)
+	if id == "" {
+		return Caller{}, errors.New("invalid access token: no user id")
+	}
+
+	role, _ := claims["role"].(string)
+
+	return Caller{UserID: id, Role: role}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// deleteAccount soft deletes the logged in user after re-authenticating them. A user
+// with two-factor enabled also gives a code, unless they re-authenticated with one.
+// The broker has dealt with the user's reservations before calling it.
+func (app *Config) deleteAccount(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	tf, err := app.Models.TwoFactor.Get(user.ID)
+	if err != nil {
+		log.Printf("Error getting two-factor state for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error deleting account"), http.StatusInternalServerError)
+		return
+	}
+	twoFactor := tf.EnabledAt != nil
+
+	// the code is checked before the password, since a correct password clears the
+	// lockout and would let wrong codes be tried without end
+	if twoFactor && user.HasPassword() && !app.verifySecondFactor(w, r, user, a) {
+		return
+	}
+
+	reauth, ok := app.reauthenticate(w, r, user, a)
+	if !ok {
+		return
+	}
+
+	if twoFactor && reauth == reauthProvider && !app.verifySecondFactor(w, r, user, a) {
+		return
+	}
+
+	if err = app.Models.User.SoftDelete(user.ID); err != nil {
+		log.Printf("Error deleting user with id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error deleting account"), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r.Context(), user.ID, "Auth_AccountDeleted", fmt.Sprintf("User with id: %v deleted their account", user.ID))
+
+	responsePayload.Data.ID = user.ID
+	responsePayload.Message = "Account deleted"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// exportData returns the logged in user's profile for a data export. The broker adds
+// the user's reservations and log entries from the other services.
+func (app *Config) exportData(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	profile, err := app.profile(user)
+	if err != nil {
+		log.Printf("Error getting profile for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error exporting data"), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r.Context(), user.ID, "Auth_DataExported", fmt.Sprintf("User with id: %v exported their data", user.ID))
+
+	responsePayload.Data.ID = user.ID
+	responsePayload.Data.Profile = profile
+	responsePayload.Message = "Data exported"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"authentication/data"
+	"authentication/mail"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"proto/requestid"
+	"time"
+)
+
+// limits on resend_verification per account
+const (
+	maxVerificationMailsPerHour = 3
+	verificationMailInterval    = time.Minute
+)
+
+// error codes returned next to the message so clients can tell these cases apart
+const (
+	codeEmailNotVerified = "email_not_verified"
+	codeRateLimited      = "rate_limited"
+)
+
+// sendVerificationEmail creates a verification token for the user and mails the link
+func (app *Config) sendVerificationEmail(user *data.User) error {
+	token, err := app.Models.EmailVerification.Create(user.ID, app.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return app.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address with the link below. It expires in %s.\n\n%s?token=%s\n",
+			user.FullName, app.EmailVerificationTTL, app.EmailVerificationURL, url.QueryEscape(token)),
+	})
+}
+
+// verifyEmail marks the account a verification token was sent to as verified
+func (app *Config) verifyEmail(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	userID, newEmail, err := app.Models.EmailVerification.Consume(a.Token)
+	if err != nil {
+		if errors.Is(err, data.ErrVerificationTokenInvalid) {
+			app.errorJSON(w, err)
+			return
+		}
+		if errors.Is(err, data.ErrEmailTaken) {
+			app.errorJSON(w, err, http.StatusConflict)
+			return
+		}
+		log.Printf("Error consuming verification token: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error verifying email"), http.StatusInternalServerError)
+		return
+	}
+
+	if newEmail != "" {
+		app.audit(r.Context(), userID, "Auth_EmailChanged", fmt.Sprintf("User with id: %v changed their email", userID))
+
+		responsePayload.Message = "Email changed"
+		app.writeJSON(w, http.StatusOK, responsePayload)
+		return
+	}
+
+	verifiedMsg := fmt.Sprintf("User with id: %v verified their email", userID)
+	log.Print(verifiedMsg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      "Auth_EmailVerified",
+		Data:      verifiedMsg,
+		UserID:    userID,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+
+	responsePayload.Message = "Email verified"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// resendVerification mails a new verification link, at most maxVerificationMailsPerHour
+// times an hour and no more often than verificationMailInterval. Like the password
+// reset request, it doesn't reveal whether the email is registered.
+func (app *Config) resendVerification(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+	responsePayload.Message = "If the email belongs to an unverified account, a verification link has been sent"
+
+	var user *data.User
+
+	user, err := user.GetByEmail(r.Context(), a.Email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error getting user for verification resend: %v\n", err)
+			app.errorJSON(w, fmt.Errorf("error resending verification"), http.StatusInternalServerError)
+			return
+		}
+		app.writeJSON(w, http.StatusOK, responsePayload)
+		return
+	}
+
+	if user.VerifiedAt != nil {
+		app.writeJSON(w, http.StatusOK, responsePayload)
+		return
+	}
+
+	count, latest, err := app.Models.EmailVerification.CountSince(user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		log.Printf("Error counting verification mails for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error resending verification"), http.StatusInternalServerError)
+		return
+	}
+
+	if count >= maxVerificationMailsPerHour || time.Since(latest) < verificationMailInterval {
+		app.errorCodeJSON(w, errors.New("too many verification emails requested, try again later"), codeRateLimited, http.StatusTooManyRequests)
+		return
+	}
+
+	if err = app.sendVerificationEmail(user); err != nil {
+		log.Printf("Error sending verification email for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error resending verification"), http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
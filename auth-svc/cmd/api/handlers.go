@@ -2,12 +2,18 @@ package main
 
 import (
 	"authentication/data"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"net/rpc"
+	"net/mail"
+	loggerpb "proto/logger"
+	"proto/requestid"
+	"proto/telemetry"
+	"strconv"
+	"time"
 )
 
 type RequestPayload struct {
@@ -16,19 +22,64 @@ type RequestPayload struct {
 }
 
 type AuthPayload struct {
-	Email    string `json:"email"`
-	FullName string `json:"fullName,omitempty"`
-	Password string `json:"password"`
+	Email        string `json:"email"`
+	FullName     string `json:"fullName,omitempty"`
+	Password     string `json:"password"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	Token        string `json:"token,omitempty"`
+
+	// UserID is the user of the access token the broker passed on, for actions that
+	// need a logged in user. A value sent in the body is ignored.
+	UserID         string `json:"userId,omitempty"`
+	Code           string `json:"code,omitempty"`
+	ChallengeToken string `json:"challengeToken,omitempty"`
+
+	// ClientIP is the address the broker received the request from. It is only taken
+	// from the body of requests from the broker; see clientIP.
+	ClientIP string `json:"clientIp,omitempty"`
+
+	// Role is the role set_role grants to the user with Email
+	Role string `json:"role,omitempty"`
+
+	// NewPassword is the password change_password sets, Password being the current one
+	NewPassword string `json:"newPassword,omitempty"`
+
+	// external provider login
+	Provider          string `json:"provider,omitempty"`
+	State             string `json:"state,omitempty"`
+	AuthorizationCode string `json:"authorizationCode,omitempty"`
 }
 
 type LogPayload struct {
 	Name string `json:"name"`
 	Data string `json:"data"`
+
+	// UserID is the user the entry is about, so it can be found for a data export
+	UserID string `json:"userId,omitempty"`
+
+	// RequestID is the ID the broker gave the request that logs the entry
+	RequestID string `json:"requestId,omitempty"`
+
+	// TraceContext carries the trace of the request that logs the entry on to the
+	// logger service
+	TraceContext map[string]string `json:"traceContext,omitempty"`
 }
 
-type RPCPayload struct {
-	Name string
-	Data string
+// actionsWithUser are the actions that act on the logged in user. They are refused
+// without a verified access token, before the user is looked up.
+var actionsWithUser = map[string]bool{
+	"2fa_enroll":  true,
+	"2fa_confirm": true,
+	"2fa_disable": true,
+	"set_role":    true,
+
+	"get_profile":     true,
+	"update_profile":  true,
+	"change_email":    true,
+	"change_password": true,
+
+	"delete_account": true,
+	"export_data":    true,
 }
 
 func (app *Config) Authenticate(w http.ResponseWriter, r *http.Request) {
@@ -40,113 +91,355 @@ func (app *Config) Authenticate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var responsePayload jsonResponse
+	caller, err := app.callerFromRequest(r)
+	if err != nil {
+		log.Printf("Rejected access token: %v\n", err)
+		app.errorJSON(w, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+	requestPayload.AuthData.UserID = caller.UserID
+	requestPayload.AuthData.ClientIP = clientIP(r, requestPayload.AuthData.ClientIP)
 
-	if requestPayload.Action == "login" {
-		var newUser *data.User
+	if actionsWithUser[requestPayload.Action] && caller.UserID == "" {
+		app.errorJSON(w, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
 
-		// check for user in database
-		newUser, err := newUser.GetByEmail(requestPayload.AuthData.Email)
-		if err != nil {
-			log.Printf("Error getting user for login: %v\n", err)
-			app.errorJSON(w, err, http.StatusUnauthorized)
-			return
+	switch requestPayload.Action {
+	case "login":
+		app.login(w, r, requestPayload.AuthData)
+	case "signup":
+		app.signup(w, r, requestPayload.AuthData)
+	case "refresh":
+		app.refresh(w, r, requestPayload.AuthData)
+	case "logout":
+		app.logout(w, r, requestPayload.AuthData)
+	case "request_password_reset":
+		app.requestPasswordReset(w, r, requestPayload.AuthData)
+	case "reset_password":
+		app.resetPassword(w, r, requestPayload.AuthData)
+	case "verify_email":
+		app.verifyEmail(w, r, requestPayload.AuthData)
+	case "resend_verification":
+		app.resendVerification(w, r, requestPayload.AuthData)
+	case "2fa_enroll":
+		app.enrollTwoFactor(w, requestPayload.AuthData)
+	case "2fa_confirm":
+		app.confirmTwoFactor(w, r, requestPayload.AuthData)
+	case "2fa_disable":
+		app.disableTwoFactor(w, r, requestPayload.AuthData)
+	case "2fa_verify":
+		app.verifyTwoFactor(w, r, requestPayload.AuthData)
+	case "set_role":
+		app.setRole(w, r, requestPayload.AuthData, caller)
+	case "get_profile":
+		app.getProfile(w, requestPayload.AuthData)
+	case "update_profile":
+		app.updateProfile(w, r, requestPayload.AuthData)
+	case "change_email":
+		app.changeEmail(w, r, requestPayload.AuthData)
+	case "change_password":
+		app.changePassword(w, r, requestPayload.AuthData)
+	case "oidc_start":
+		app.oidcStart(w, requestPayload.AuthData)
+	case "oidc_callback":
+		app.oidcCallback(w, r, requestPayload.AuthData)
+	case "delete_account":
+		app.deleteAccount(w, r, requestPayload.AuthData)
+	case "export_data":
+		app.exportData(w, r, requestPayload.AuthData)
+	default:
+		var responsePayload jsonResponse
+		responsePayload.Error = true
+		responsePayload.Message = "Invalid Action"
+		app.writeJSON(w, http.StatusOK, responsePayload)
+	}
+}
+
+func (app *Config) login(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	if app.loginLocked(w, a) {
+		return
+	}
+
+	var newUser *data.User
+
+	// check for user in database
+	newUser, err := newUser.GetByEmail(r.Context(), a.Email)
+	if err != nil {
+		log.Printf("Error getting user for login: %v\n", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			app.recordLoginFailure(r.Context(), a)
 		}
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	// password match
+	_, err = newUser.PasswordMatches(a.Password)
+	if err != nil {
+		log.Printf("Login error: %v for id: %v\n", err, newUser.ID)
+		app.recordLoginFailure(r.Context(), a)
+		app.errorJSON(w, fmt.Errorf("invalid credentials"), http.StatusUnauthorized)
+		return
+	}
+
+	// hashes made with outdated settings are upgraded while the password is at hand
+	if rehashed, err := newUser.RehashPassword(a.Password); err != nil {
+		log.Printf("Error rehashing password for id: %v: %v\n", newUser.ID, err)
+	} else if rehashed {
+		log.Printf("Rehashed password for id: %v\n", newUser.ID)
+	}
+
+	if app.EmailVerificationEnabled && newUser.VerifiedAt == nil {
+		log.Printf("Login refused for unverified id: %v\n", newUser.ID)
+		app.errorCodeJSON(w, errors.New("email address is not verified"), codeEmailNotVerified, http.StatusForbidden)
+		return
+	}
+
+	app.startSession(w, r, newUser, fmt.Sprintf("User with id: %v logged in", newUser.ID))
+}
 
-		// password match
-		_, err = newUser.PasswordMatches(requestPayload.AuthData.Password)
+// startSession finishes a login once the first factor checked out. With two-factor
+// enabled that only earns a challenge, exchanged for tokens by 2fa_verify.
+func (app *Config) startSession(w http.ResponseWriter, r *http.Request, user *data.User, loginMsg string) {
+	var responsePayload jsonResponse
+
+	tf, err := app.Models.TwoFactor.Get(user.ID)
+	if err != nil {
+		log.Printf("Error getting two-factor settings for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error logging in"), http.StatusInternalServerError)
+		return
+	}
+
+	if tf.EnabledAt != nil {
+		challenge, err := app.Models.LoginChallenge.Create(user.ID, app.LoginChallengeTTL)
 		if err != nil {
-			log.Printf("Login error: %v for id: %v\n", err, newUser.ID)
-			app.errorJSON(w, fmt.Errorf("invalid credentials"), http.StatusUnauthorized)
+			log.Printf("Error creating login challenge for id: %v: %v\n", user.ID, err)
+			app.errorJSON(w, fmt.Errorf("error logging in"), http.StatusInternalServerError)
 			return
 		}
 
-		loginMsg := fmt.Sprintf("User with id: %v logged in", newUser.ID)
-		log.Print(loginMsg)
+		responsePayload.Data.TwoFactorRequired = true
+		responsePayload.Data.ChallengeToken = challenge
+		responsePayload.Message = "Two-factor authentication required"
+		app.writeJSON(w, http.StatusOK, responsePayload)
+		return
+	}
+
+	app.completeLogin(w, r, user, loginMsg)
+}
 
-		// log user login
-		logData := LogPayload{
-			Name: "Auth_Login",
-			Data: loginMsg,
-		}
+// completeLogin logs a successful login and hands out a new refresh token. The
+// account's failed logins are only cleared here, once every factor checked out, so a
+// correct password alone doesn't reset the count of wrong two-factor codes.
+func (app *Config) completeLogin(w http.ResponseWriter, r *http.Request, user *data.User, loginMsg string) {
+	var responsePayload jsonResponse
 
-		app.logItemViaRPC(logData)
+	userID := user.ID
+
+	app.resetLoginFailures(AuthPayload{Email: user.Email})
+
+	log.Print(loginMsg)
+
+	// log user login
+	logData := LogPayload{
+		Name:         "Auth_Login",
+		Data:         loginMsg,
+		UserID:       userID,
+		RequestID:    requestid.FromContext(r.Context()),
+		TraceContext: telemetry.Inject(r.Context()),
+	}
 
-		// return login success
-		responsePayload.Data.ID = newUser.ID
-		responsePayload.Message = "Login success"
+	app.logItemViaRPC(logData)
+
+	refreshToken, err := app.Models.RefreshToken.Issue(userID, app.RefreshTokenTTL)
+	if err != nil {
+		log.Printf("Error issuing refresh token for id: %v: %v\n", userID, err)
+		app.errorJSON(w, fmt.Errorf("error issuing refresh token"), http.StatusInternalServerError)
+		return
+	}
+
+	// return login success
+	responsePayload.Data.ID = userID
+	responsePayload.Data.Role = user.Role
+	responsePayload.Data.RefreshToken = refreshToken
+	responsePayload.Message = "Login success"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+func (app *Config) signup(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	if !validEmail(a.Email) {
+		app.errorJSON(w, errors.New("invalid email address"))
+		return
+	}
+
+	if err := app.PasswordPolicy.Check(a.Password, a.Email); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// check for user in database
+	var newUser *data.User
+
+	// check for user in database
+	newUser, err := newUser.GetByEmail(r.Context(), a.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting user: %v\n", err)
+		app.errorJSON(w, err)
+		return
+	}
+
+	if newUser != nil {
+		log.Printf("User with id: %v already exists\n", newUser.ID)
+		responsePayload.Error = true
+		responsePayload.Message = "User already exists"
 		app.writeJSON(w, http.StatusOK, responsePayload)
-	} else if requestPayload.Action == "signup" {
-		// check for user in database
-		var newUser *data.User
-
-		// check for user in database
-		newUser, err := newUser.GetByEmail(requestPayload.AuthData.Email)
-		if err != nil && !errors.Is(err, sql.ErrNoRows) {
-			log.Printf("Error getting user: %v\n", err)
-			app.errorJSON(w, err)
-			return
+		return
+	}
+
+	user := data.User{
+		Email:    a.Email,
+		Password: a.Password,
+		FullName: a.FullName,
+	}
+
+	// without verification, accounts are verified from the start
+	if !app.EmailVerificationEnabled {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
+
+	// create user
+	id, err := newUser.Insert(r.Context(), user)
+	if err != nil {
+		log.Printf("Error inserting user: %v\n", err)
+		responsePayload.Error = true
+		responsePayload.Message = "Error inserting user"
+		app.writeJSON(w, http.StatusAccepted, responsePayload)
+		return
+	}
+
+	signupMsg := fmt.Sprintf("New User with id: %d created", id)
+	log.Print(signupMsg)
+
+	// log user signup via RPC
+	logData := LogPayload{
+		Name:         "Auth_Signup",
+		Data:         signupMsg,
+		UserID:       strconv.Itoa(id),
+		RequestID:    requestid.FromContext(r.Context()),
+		TraceContext: telemetry.Inject(r.Context()),
+	}
+
+	app.logItemViaRPC(logData)
+
+	if app.EmailVerificationEnabled {
+		user.ID = strconv.Itoa(id)
+		if err = app.sendVerificationEmail(&user); err != nil {
+			log.Printf("Error sending verification email for id: %d: %v\n", id, err)
 		}
+	}
+
+	// return signup success
+	responsePayload.Message = "Signup success"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
 
-		if newUser != nil {
-			log.Printf("User with id: %v already exists\n", newUser.ID)
-			responsePayload.Error = true
-			responsePayload.Message = "User already exists"
-			app.writeJSON(w, http.StatusOK, responsePayload)
+// refresh rotates a refresh token. Reusing a rotated token revokes its whole family.
+func (app *Config) refresh(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	userID, refreshToken, err := app.Models.RefreshToken.Rotate(a.RefreshToken, app.RefreshTokenTTL)
+	if err != nil {
+		if errors.Is(err, data.ErrRefreshTokenReused) {
+			reuseMsg := fmt.Sprintf("Refresh token reuse detected for user with id: %v, sessions revoked", userID)
+			log.Print(reuseMsg)
+
+			app.logItemViaRPC(LogPayload{
+				Name:      "Auth_RefreshTokenReuse",
+				Data:      reuseMsg,
+				UserID:    userID,
+				RequestID: requestid.FromContext(r.Context()),
+			})
+
+			app.errorJSON(w, data.ErrRefreshTokenInvalid, http.StatusUnauthorized)
 			return
 		}
-
-		// create user
-		id, err := newUser.Insert(data.User{
-			Email:    requestPayload.AuthData.Email,
-			Password: requestPayload.AuthData.Password,
-			FullName: requestPayload.AuthData.FullName,
-		})
-		if err != nil {
-			log.Printf("Error inserting user: %v\n", err)
-			responsePayload.Error = true
-			responsePayload.Message = "Error inserting user"
-			app.writeJSON(w, http.StatusAccepted, responsePayload)
+		if errors.Is(err, data.ErrRefreshTokenInvalid) {
+			app.errorJSON(w, err, http.StatusUnauthorized)
 			return
 		}
+		log.Printf("Error rotating refresh token: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error refreshing token"), http.StatusInternalServerError)
+		return
+	}
 
-		signupMsg := fmt.Sprintf("New User with id: %d created", id)
-		log.Print(signupMsg)
+	// the role is read again on every refresh, so role changes reach the access token
+	// without logging in again
+	user, err := app.Models.User.GetByID(userID)
+	if err != nil {
+		log.Printf("Error getting user with id: %v: %v\n", userID, err)
+		app.errorJSON(w, fmt.Errorf("error refreshing token"), http.StatusInternalServerError)
+		return
+	}
 
-		// log user signup via RPC
-		logData := LogPayload{
-			Name: "Auth_Signup",
-			Data: signupMsg,
-		}
+	responsePayload.Data.ID = userID
+	responsePayload.Data.Role = user.Role
+	responsePayload.Data.RefreshToken = refreshToken
+	responsePayload.Message = "Refresh success"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
 
-		app.logItemViaRPC(logData)
+// logout revokes the refresh token family the presented token belongs to
+func (app *Config) logout(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
 
-		// return signup success
-		responsePayload.Message = "Signup success"
-		app.writeJSON(w, http.StatusOK, responsePayload)
-	} else {
-		responsePayload.Error = true
-		responsePayload.Message = "Invalid Action"
-		app.writeJSON(w, http.StatusOK, responsePayload)
+	userID, err := app.Models.RefreshToken.RevokeFamily(a.RefreshToken)
+	if err != nil {
+		if errors.Is(err, data.ErrRefreshTokenInvalid) {
+			app.errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Error revoking refresh token: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error logging out"), http.StatusInternalServerError)
+		return
 	}
 
+	logoutMsg := fmt.Sprintf("User with id: %v logged out", userID)
+	log.Print(logoutMsg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      "Auth_Logout",
+		Data:      logoutMsg,
+		UserID:    userID,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+
+	responsePayload.Message = "Logout success"
+	app.writeJSON(w, http.StatusOK, responsePayload)
 }
 
-func (app *Config) logItemViaRPC(l LogPayload) {
-	client, err := rpc.Dial("tcp", "logger-svc:5001")
-	if err != nil {
-		log.Println("Error connecting to logger rpc from auth: ", err)
-	}
+// validEmail reports whether email is a bare address, without a display name
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
 
-	var rpcPayload RPCPayload
-	rpcPayload.Name = l.Name
-	rpcPayload.Data = l.Data
+func (app *Config) logItemViaRPC(l LogPayload) {
+	ctx := telemetry.Extract(context.Background(), l.TraceContext)
 
-	var result string
-	err = client.Call("RPCServer.LogInfoViaRPC", rpcPayload, &result)
+	reply, err := app.Logger.Log(ctx, &loggerpb.LogRequest{
+		Name:      l.Name,
+		Data:      l.Data,
+		UserId:    l.UserID,
+		RequestId: l.RequestID,
+	})
 	if err != nil {
 		log.Println("Error sending payload to logger rpc from auth: ", err)
+		return
 	}
 
-	log.Println(result)
+	log.Println(reply.GetMessage())
 }
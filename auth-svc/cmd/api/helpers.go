@@ -9,12 +9,26 @@ import (
 
 type jsonResponse struct {
 	Error   bool          `json:"error"`
+	Code    string        `json:"code,omitempty"`
 	Message string        `json:"message"`
 	Data    LoginResponse `json:"data,omitempty"`
 }
 
 type LoginResponse struct {
-	ID string `json:"id"`
+	ID           string `json:"id"`
+	Role         string `json:"role,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	// two-factor login and enrollment
+	TwoFactorRequired bool     `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string   `json:"challengeToken,omitempty"`
+	Secret            string   `json:"secret,omitempty"`
+	OtpauthURI        string   `json:"otpauthURI,omitempty"`
+	RecoveryCodes     []string `json:"recoveryCodes,omitempty"`
+
+	Profile *Profile `json:"profile,omitempty"`
+
+	AuthorizationURL string `json:"authorizationURL,omitempty"`
 }
 
 func (app *Config) readJSON(w http.ResponseWriter, r *http.Request, data any) error {
@@ -71,3 +85,13 @@ func (app *Config) errorJSON(w http.ResponseWriter, err error, status ...int) er
 
 	return app.writeJSON(w, statusCode, payload)
 }
+
+// errorCodeJSON is errorJSON with a machine readable error code
+func (app *Config) errorCodeJSON(w http.ResponseWriter, err error, code string, status int) error {
+	var payload jsonResponse
+	payload.Error = true
+	payload.Code = code
+	payload.Message = err.Error()
+
+	return app.writeJSON(w, status, payload)
+}
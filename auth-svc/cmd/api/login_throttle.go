@@ -0,0 +1,123 @@
+package main
+
+import (
+	"authentication/data"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"proto/requestid"
+	"strconv"
+	"time"
+)
+
+// Failed logins are counted per account and per client IP. An IP gets more free
+// failures than an account since several users may share one.
+var (
+	accountThrottle = data.ThrottlePolicy{
+		FreeFailures: 5,
+		BaseLockout:  time.Minute,
+		MaxLockout:   time.Hour,
+		Window:       time.Hour,
+	}
+	ipThrottle = data.ThrottlePolicy{
+		FreeFailures: 20,
+		BaseLockout:  time.Minute,
+		MaxLockout:   time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+const (
+	codeAccountLocked   = "account_locked"
+	codeTooManyAttempts = "too_many_attempts"
+)
+
+// loginLocked writes a 423 or 429 response and returns true if the account or the
+// client IP is locked out. It runs before the password is checked, so locked out
+// clients don't cost a bcrypt comparison.
+func (app *Config) loginLocked(w http.ResponseWriter, a AuthPayload) bool {
+	lockedUntil, err := app.Models.LoginThrottle.Locked(data.AccountThrottleKey(a.Email))
+	if err != nil {
+		log.Printf("Error checking account lockout: %v\n", err)
+	} else if !lockedUntil.IsZero() {
+		app.lockedJSON(w, errors.New("account temporarily locked after too many failed logins"), codeAccountLocked, http.StatusLocked, lockedUntil)
+		return true
+	}
+
+	if key := data.IPThrottleKey(a.ClientIP); key != "" {
+		lockedUntil, err = app.Models.LoginThrottle.Locked(key)
+		if err != nil {
+			log.Printf("Error checking ip lockout: %v\n", err)
+		} else if !lockedUntil.IsZero() {
+			app.lockedJSON(w, errors.New("too many failed logins, try again later"), codeTooManyAttempts, http.StatusTooManyRequests, lockedUntil)
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordLoginFailure counts a failed login against the account and the client IP, and
+// logs any lockout it causes
+func (app *Config) recordLoginFailure(ctx context.Context, a AuthPayload) {
+	lockedUntil, err := app.Models.LoginThrottle.RecordFailure(data.AccountThrottleKey(a.Email), accountThrottle)
+	if err != nil {
+		log.Printf("Error recording failed login: %v\n", err)
+	} else if !lockedUntil.IsZero() {
+		// the entry names the user by ID rather than by the email tried, so it holds no
+		// personal data
+		userID := "unknown"
+		if user, err := app.Models.User.GetByEmail(ctx, a.Email); err == nil {
+			userID = user.ID
+		}
+		app.logLockout(ctx, "Auth_AccountLocked", fmt.Sprintf("Account of user with id: %v locked until %s after failed logins", userID, lockedUntil.Format(time.RFC3339)))
+	}
+
+	if key := data.IPThrottleKey(a.ClientIP); key != "" {
+		lockedUntil, err = app.Models.LoginThrottle.RecordFailure(key, ipThrottle)
+		if err != nil {
+			log.Printf("Error recording failed login: %v\n", err)
+		} else if !lockedUntil.IsZero() {
+			app.logLockout(ctx, "Auth_IPLocked", fmt.Sprintf("IP %s locked until %s after failed logins", a.ClientIP, lockedUntil.Format(time.RFC3339)))
+		}
+	}
+}
+
+// resetLoginFailures clears the account's failures after a successful login. The IP
+// counter is left to expire, so one good account can't unlock an attacker's IP.
+func (app *Config) resetLoginFailures(a AuthPayload) {
+	if err := app.Models.LoginThrottle.Reset(data.AccountThrottleKey(a.Email)); err != nil {
+		log.Printf("Error resetting failed logins: %v\n", err)
+	}
+}
+
+func (app *Config) logLockout(ctx context.Context, name, msg string) {
+	log.Print(msg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      name,
+		Data:      msg,
+		RequestID: requestid.FromContext(ctx),
+	})
+}
+
+// lockedJSON is errorCodeJSON with a Retry-After header
+func (app *Config) lockedJSON(w http.ResponseWriter, err error, code string, status int, until time.Time) error {
+	var payload jsonResponse
+	payload.Error = true
+	payload.Code = code
+	payload.Message = err.Error()
+
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	headers := http.Header{}
+	headers.Set("Retry-After", strconv.Itoa(retryAfter))
+
+	return app.writeJSON(w, status, payload, headers)
+}
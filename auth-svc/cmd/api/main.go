@@ -2,23 +2,86 @@ package main
 
 import (
 	"authentication/data"
+	"authentication/mail"
+	"authentication/oidc"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	loggerpb "proto/logger"
+	"proto/rpcclient"
+	"proto/telemetry"
+	"strconv"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
 const webPort = "8181"
 
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 15 * time.Second
+
+const (
+	defaultRefreshTokenTTL  = 30 * 24 * time.Hour
+	defaultPasswordResetTTL = time.Hour
+	defaultPasswordResetURL = "http://localhost:3000/reset-password"
+
+	defaultEmailVerificationTTL = 48 * time.Hour
+	defaultEmailVerificationURL = "http://localhost:3000/verify-email"
+
+	defaultLoginChallengeTTL = 5 * time.Minute
+	defaultTOTPIssuer        = "Booking System"
+
+	defaultOIDCStateTTL = 10 * time.Minute
+)
+
 type Config struct {
-	DB     *sql.DB
-	Models data.Models
+	DB               *sql.DB
+	Models           data.Models
+	Mailer           mail.Sender
+	RefreshTokenTTL  time.Duration
+	PasswordResetTTL time.Duration
+	PasswordResetURL string
+
+	EmailVerificationEnabled bool
+	EmailVerificationTTL     time.Duration
+	EmailVerificationURL     string
+
+	LoginChallengeTTL time.Duration
+	TOTPIssuer        string
+
+	OIDCProviders map[string]*oidc.Provider
+	OIDCStateTTL  time.Duration
+
+	PasswordPolicy *data.PasswordPolicy
+
+	// Logger sends log entries to the logger service
+	Logger loggerpb.LoggerServiceClient
+
+	// AccessTokenKeys are the broker's keys, the access tokens it passes on are
+	// verified with
+	AccessTokenKeys *oidc.RemoteKeySet
 }
 
 func main() {
+	shutdownTracing, err := telemetry.Setup(context.Background(), "auth-svc")
+	if err != nil {
+		log.Fatal("Error setting up tracing: ", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Println("Error flushing traces: ", err)
+		}
+	}()
 
 	conn := connectToDB()
 	if conn == nil {
@@ -27,22 +90,108 @@ func main() {
 
 	defer conn.Close()
 
+	mailer, err := mail.New(os.Getenv("MAIL_SENDER"), os.Getenv("MAIL_DIR"))
+	if err != nil {
+		log.Fatal("Error setting up mail sender: ", err)
+	}
+
+	providers, err := loadOIDCProviders()
+	if err != nil {
+		log.Fatal("Error setting up OpenID providers: ", err)
+	}
+
+	serverTLS, rpcTLS, err := loadRPCTLS(os.Getenv("RPC_ALLOWED_CLIENTS"))
+	if err != nil {
+		log.Fatal("Error loading RPC certificates: ", err)
+	}
+
+	loggerRPC, err := rpcclient.New(rpcclient.Config{Addr: "logger-svc:50001", TLS: rpcTLS})
+	if err != nil {
+		log.Fatal("Error setting up logger gRPC client: ", err)
+	}
+	defer loggerRPC.Close()
+
+	hasher := passwordHasherFromEnv()
+	if err = hasher.Validate(); err != nil {
+		log.Fatal("Invalid password hashing settings: ", err)
+	}
+
+	policy, err := data.NewPasswordPolicy(intFromEnv("PASSWORD_MIN_LENGTH", data.DefaultPasswordMinLength), os.Getenv("BREACHED_PASSWORDS_FILE"))
+	if err != nil {
+		log.Fatal("Error loading breached passwords: ", err)
+	}
+	log.Printf("Hashing passwords with %s, refusing %d breached passwords\n", hasher.Algorithm, policy.BreachedCount())
+
 	app := Config{
-		DB:     conn,
-		Models: data.New(conn),
+		DB:               conn,
+		Models:           data.New(conn, hasher),
+		Mailer:           mailer,
+		RefreshTokenTTL:  durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+		PasswordResetTTL: durationFromEnv("PASSWORD_RESET_TTL", defaultPasswordResetTTL),
+		PasswordResetURL: stringFromEnv("PASSWORD_RESET_URL", defaultPasswordResetURL),
+
+		EmailVerificationEnabled: boolFromEnv("EMAIL_VERIFICATION_ENABLED", true),
+		EmailVerificationTTL:     durationFromEnv("EMAIL_VERIFICATION_TTL", defaultEmailVerificationTTL),
+		EmailVerificationURL:     stringFromEnv("EMAIL_VERIFICATION_URL", defaultEmailVerificationURL),
+
+		LoginChallengeTTL: durationFromEnv("LOGIN_CHALLENGE_TTL", defaultLoginChallengeTTL),
+		TOTPIssuer:        stringFromEnv("TOTP_ISSUER", defaultTOTPIssuer),
+
+		OIDCProviders: providers,
+		OIDCStateTTL:  durationFromEnv("OIDC_STATE_TTL", defaultOIDCStateTTL),
+
+		PasswordPolicy: policy,
+
+		Logger: loggerpb.NewLoggerServiceClient(loggerRPC),
+
+		AccessTokenKeys: oidc.NewRemoteKeySet(stringFromEnv("ACCESS_TOKEN_JWKS_URL", defaultAccessTokenJWKSURL), &http.Client{Timeout: 5 * time.Second}),
 	}
 
+	app.bootstrapAdmins(os.Getenv("ADMIN_EMAILS"))
+
+	// the port only serves the services allowed by RPC_ALLOWED_CLIENTS, over mutual TLS
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%s", webPort),
-		Handler: app.routes(),
+		Addr:      fmt.Sprintf(":%s", webPort),
+		Handler:   app.routes(),
+		TLSConfig: serverTLS,
 	}
 
 	log.Println("Authentication service started on port: ", webPort)
 
-	err := srv.ListenAndServe()
-	if err != nil {
+	// Finish the requests in flight on SIGINT or SIGTERM before the RPC client and the
+	// database close
+	stopped := shutdownOnSignal(srv)
+
+	err = srv.ListenAndServeTLS("", "")
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Panic(err)
 	}
+
+	<-stopped
+}
+
+// shutdownOnSignal stops srv gracefully on SIGINT or SIGTERM. The returned channel is
+// closed once the requests in flight are done, or shutdownTimeout passed.
+func shutdownOnSignal(srv *http.Server) <-chan struct{} {
+	stopped := make(chan struct{})
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		log.Println("Shutting down authentication service")
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Println("Error shutting down the server: ", err)
+		}
+		close(stopped)
+	}()
+
+	return stopped
 }
 
 func connectToDB() *sql.DB {
@@ -64,3 +213,77 @@ func connectToDB() *sql.DB {
 
 	return db
 }
+
+// passwordHasherFromEnv reads the algorithm new password hashes are made with and its
+// parameters. Hashes made with other settings are upgraded as their users log in.
+func passwordHasherFromEnv() data.PasswordHasher {
+	hasher := data.DefaultPasswordHasher()
+
+	hasher.Algorithm = stringFromEnv("PASSWORD_HASH_ALGORITHM", hasher.Algorithm)
+	hasher.BcryptCost = intFromEnv("BCRYPT_COST", hasher.BcryptCost)
+	hasher.Argon2.Memory = uint32(intFromEnv("ARGON2_MEMORY_KIB", int(hasher.Argon2.Memory)))
+	hasher.Argon2.Iterations = uint32(intFromEnv("ARGON2_ITERATIONS", int(hasher.Argon2.Iterations)))
+	hasher.Argon2.Parallelism = uint8(intFromEnv("ARGON2_PARALLELISM", int(hasher.Argon2.Parallelism)))
+
+	return hasher
+}
+
+// durationFromEnv reads a duration such as "720h" from the environment, falling back
+// to def when the variable is unset or invalid
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s\n", key, value, def)
+		return def
+	}
+
+	return d
+}
+
+// stringFromEnv reads a string from the environment, falling back to def when unset
+func stringFromEnv(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// intFromEnv reads a positive integer from the environment, falling back to def when
+// the variable is unset or invalid
+func intFromEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 || n > 1<<22 {
+		log.Printf("Invalid %s %q, using %d\n", key, value, def)
+		return def
+	}
+
+	return n
+}
+
+// boolFromEnv reads a boolean such as "true" or "0" from the environment, falling
+// back to def when the variable is unset or invalid
+func boolFromEnv(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %t\n", key, value, def)
+		return def
+	}
+
+	return b
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"authentication/data"
+	"authentication/oidc"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// oidcTimeout bounds the calls to the provider during a login
+const oidcTimeout = 10 * time.Second
+
+var (
+	errUnknownProvider = errors.New("unknown identity provider")
+	errProviderLogin   = errors.New("identity provider login failed")
+	errNoVerifiedEmail = errors.New("identity provider did not return a verified email address")
+)
+
+// loadOIDCProviders sets up the providers named in OIDC_PROVIDERS, a comma separated
+// list such as "google,okta". Each one is configured with OIDC_<NAME>_ISSUER,
+// _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and optionally _SCOPES.
+func loadOIDCProviders() (map[string]*oidc.Provider, error) {
+	providers := map[string]*oidc.Provider{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+
+		if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+		}
+
+		providers[name] = oidc.NewProvider(cfg, &http.Client{Timeout: oidcTimeout})
+		log.Printf("OpenID provider %s configured with issuer: %s\n", name, cfg.Issuer)
+	}
+
+	return providers, nil
+}
+
+// oidcStart begins a login with an external provider and returns the URL to send the
+// user to. The state, nonce and PKCE verifier are kept until the callback.
+func (app *Config) oidcStart(w http.ResponseWriter, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	provider, ok := app.OIDCProviders[a.Provider]
+	if !ok {
+		app.errorJSON(w, errUnknownProvider)
+		return
+	}
+
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		log.Printf("Error generating nonce: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error starting login"), http.StatusInternalServerError)
+		return
+	}
+
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		log.Printf("Error generating code verifier: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error starting login"), http.StatusInternalServerError)
+		return
+	}
+
+	state, err := app.Models.OIDCState.Create(a.Provider, nonce, verifier, app.OIDCStateTTL)
+	if err != nil {
+		log.Printf("Error storing login state: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error starting login"), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcTimeout)
+	defer cancel()
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error building authorization url for %v: %v\n", a.Provider, err)
+		app.errorJSON(w, fmt.Errorf("error starting login"), http.StatusBadGateway)
+		return
+	}
+
+	responsePayload.Data.AuthorizationURL = authURL
+	responsePayload.Message = "Redirect to the identity provider"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// oidcCallback finishes a provider login: it exchanges the code, validates the ID
+// token and logs in the linked user. An unknown subject is linked to the account with
+// the same, provider verified, email, or gets a new account.
+func (app *Config) oidcCallback(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	providerName, claims, err := app.providerLogin(a)
+	switch {
+	case errors.Is(err, data.ErrOIDCStateInvalid), errors.Is(err, errProviderLogin):
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	case errors.Is(err, errUnknownProvider):
+		app.errorJSON(w, err)
+		return
+	case err != nil:
+		log.Printf("Error consuming login state: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error logging in"), http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := app.Models.UserIdentity.FindUser(providerName, claims.Subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		userID, err = app.linkIdentity(r.Context(), providerName, claims)
+		if errors.Is(err, errNoVerifiedEmail) {
+			app.errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("Error finding user for %v subject: %v: %v\n", providerName, claims.Subject, err)
+		app.errorJSON(w, fmt.Errorf("error logging in"), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := app.Models.User.GetByID(userID)
+	if err != nil {
+		log.Printf("Error getting user with id: %v: %v\n", userID, err)
+		app.errorJSON(w, errProviderLogin, http.StatusUnauthorized)
+		return
+	}
+
+	app.startSession(w, r, user, fmt.Sprintf("User with id: %v logged in with %v", user.ID, providerName))
+}
+
+// providerLogin completes the provider login that a.State and a.AuthorizationCode
+// came back from, and returns the provider's name and the validated ID token claims.
+// An unknown or expired state gives data.ErrOIDCStateInvalid, a failed exchange
+// errProviderLogin.
+func (app *Config) providerLogin(a AuthPayload) (string, *oidc.Claims, error) {
+	state, err := app.Models.OIDCState.Consume(a.State)
+	if err != nil {
+		return "", nil, err
+	}
+
+	provider, ok := app.OIDCProviders[state.Provider]
+	if !ok {
+		return "", nil, errUnknownProvider
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcTimeout)
+	defer cancel()
+
+	claims, err := provider.Exchange(ctx, a.AuthorizationCode, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Error completing %v login: %v\n", state.Provider, err)
+		return "", nil, errProviderLogin
+	}
+
+	return state.Provider, claims, nil
+}
+
+// linkIdentity links a new provider subject to the account with the same email, or
+// creates an account for it. The email must be verified by the provider, or anyone
+// could claim an account by registering its address there.
+func (app *Config) linkIdentity(ctx context.Context, provider string, claims *oidc.Claims) (string, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return "", errNoVerifiedEmail
+	}
+
+	existing, err := app.Models.User.GetByEmail(ctx, claims.Email)
+	if err == nil {
+		// an unverified account may have been registered by someone else in the
+		// owner's name; its password is dropped so only the owner gets in
+		if existing.VerifiedAt == nil {
+			if err = app.Models.User.ClaimUnverified(existing.ID); err != nil {
+				return "", err
+			}
+		}
+
+		if err = app.Models.UserIdentity.Link(existing.ID, provider, claims.Subject, claims.Email); err != nil {
+			return "", err
+		}
+
+		app.audit(ctx, existing.ID, "Auth_IdentityLinked", fmt.Sprintf("User with id: %v linked their %v account", existing.ID, provider))
+		return existing.ID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	fullName := strings.TrimSpace(claims.Name)
+	if fullName == "" {
+		fullName, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	now := time.Now()
+	userID, err := app.Models.UserIdentity.CreateUser(data.User{
+		Email:      claims.Email,
+		FullName:   fullName,
+		VerifiedAt: &now,
+	}, provider, claims.Subject)
+	if err != nil {
+		return "", err
+	}
+
+	app.audit(ctx, userID, "Auth_Signup", fmt.Sprintf("New User with id: %v created with %v", userID, provider))
+	return userID, nil
+}
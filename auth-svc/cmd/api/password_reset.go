@@ -0,0 +1,98 @@
+package main
+
+import (
+	"authentication/data"
+	"authentication/mail"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"proto/requestid"
+)
+
+// requestPasswordReset mails a reset link to the user. The response is the same
+// whether or not the email belongs to an account, so it can't be used to probe for
+// registered addresses.
+func (app *Config) requestPasswordReset(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+	responsePayload.Message = "If the email belongs to an account, a reset link has been sent"
+
+	var user *data.User
+
+	user, err := user.GetByEmail(r.Context(), a.Email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error getting user for password reset: %v\n", err)
+			app.errorJSON(w, fmt.Errorf("error requesting password reset"), http.StatusInternalServerError)
+			return
+		}
+		app.writeJSON(w, http.StatusOK, responsePayload)
+		return
+	}
+
+	token, err := app.Models.PasswordReset.Create(user.ID, app.PasswordResetTTL)
+	if err != nil {
+		log.Printf("Error creating password reset token for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error requesting password reset"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s?token=%s\n\nIf you didn't ask for this, you can ignore this email.",
+			user.FullName, app.PasswordResetTTL, app.PasswordResetURL, url.QueryEscape(token)),
+	})
+	if err != nil {
+		log.Printf("Error sending password reset email for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error requesting password reset"), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Password reset requested for user with id: %v\n", user.ID)
+
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// resetPassword sets a new password using a reset token and ends all of the user's
+// sessions
+func (app *Config) resetPassword(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	if a.Password == "" {
+		app.errorJSON(w, errors.New("password is required"))
+		return
+	}
+
+	// checked before the token is used up, so the user can try another password
+	if err := app.PasswordPolicy.Check(a.Password, ""); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	userID, err := app.Models.PasswordReset.ResetPassword(a.Token, a.Password)
+	if err != nil {
+		if errors.Is(err, data.ErrResetTokenInvalid) {
+			app.errorJSON(w, err)
+			return
+		}
+		log.Printf("Error resetting password: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error resetting password"), http.StatusInternalServerError)
+		return
+	}
+
+	resetMsg := fmt.Sprintf("User with id: %v reset their password", userID)
+	log.Print(resetMsg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      "Auth_PasswordReset",
+		Data:      resetMsg,
+		UserID:    userID,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+
+	responsePayload.Message = "Password reset success"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"authentication/data"
+	"authentication/mail"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"proto/requestid"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxFullNameLength = 100
+
+var (
+	errWrongPassword  = errors.New("current password is incorrect")
+	errReauthRequired = errors.New("log in with your identity provider again, or give a two-factor code, to confirm this change")
+)
+
+// reauthentication is how a user proved who they are before a sensitive change
+type reauthentication int
+
+const (
+	reauthPassword reauthentication = iota
+	reauthProvider
+	reauthSecondFactor
+)
+
+// Profile is what get_profile returns about the logged in user
+type Profile struct {
+	ID               string    `json:"id"`
+	Email            string    `json:"email"`
+	FullName         string    `json:"fullName"`
+	Role             string    `json:"role"`
+	EmailVerified    bool      `json:"emailVerified"`
+	TwoFactorEnabled bool      `json:"twoFactorEnabled"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// getProfile returns the logged in user's profile
+func (app *Config) getProfile(w http.ResponseWriter, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	profile, err := app.profile(user)
+	if err != nil {
+		log.Printf("Error getting profile for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error getting profile"), http.StatusInternalServerError)
+		return
+	}
+
+	responsePayload.Data.ID = user.ID
+	responsePayload.Data.Profile = profile
+	responsePayload.Message = "Profile fetched"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// profile builds the Profile of a user
+func (app *Config) profile(user *data.User) (*Profile, error) {
+	tf, err := app.Models.TwoFactor.Get(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Profile{
+		ID:               user.ID,
+		Email:            user.Email,
+		FullName:         user.FullName,
+		Role:             user.Role,
+		EmailVerified:    user.VerifiedAt != nil,
+		TwoFactorEnabled: tf.EnabledAt != nil,
+		CreatedAt:        user.CreatedAt,
+	}, nil
+}
+
+// updateProfile changes the logged in user's full name
+func (app *Config) updateProfile(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	fullName := strings.TrimSpace(a.FullName)
+	if fullName == "" || utf8.RuneCountInString(fullName) > maxFullNameLength {
+		app.errorJSON(w, fmt.Errorf("full name must be between 1 and %d characters", maxFullNameLength))
+		return
+	}
+
+	if err := app.Models.User.UpdateFullName(user.ID, fullName); err != nil {
+		log.Printf("Error updating full name for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error updating profile"), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r.Context(), user.ID, "Auth_ProfileUpdated", fmt.Sprintf("User with id: %v changed their full name", user.ID))
+
+	responsePayload.Data.ID = user.ID
+	responsePayload.Message = "Profile updated"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// changeEmail moves the account to a new email address. With verification enabled the
+// change only happens once a link sent to the new address is followed, so a typo
+// can't lock the user out; the old address is told about the request.
+func (app *Config) changeEmail(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	if _, ok := app.reauthenticate(w, r, user, a); !ok {
+		return
+	}
+
+	newEmail := a.Email
+	if !validEmail(newEmail) {
+		app.errorJSON(w, errors.New("invalid email address"))
+		return
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		app.errorJSON(w, errors.New("new email is the same as the current one"))
+		return
+	}
+
+	_, err := app.Models.User.GetByEmail(r.Context(), newEmail)
+	if err == nil {
+		app.errorJSON(w, data.ErrEmailTaken, http.StatusConflict)
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting user: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error changing email"), http.StatusInternalServerError)
+		return
+	}
+
+	if !app.EmailVerificationEnabled {
+		err = app.Models.User.UpdateEmail(user.ID, newEmail)
+		if err != nil {
+			if errors.Is(err, data.ErrEmailTaken) {
+				app.errorJSON(w, err, http.StatusConflict)
+				return
+			}
+			log.Printf("Error updating email for id: %v: %v\n", user.ID, err)
+			app.errorJSON(w, fmt.Errorf("error changing email"), http.StatusInternalServerError)
+			return
+		}
+
+		app.audit(r.Context(), user.ID, "Auth_EmailChanged", fmt.Sprintf("User with id: %v changed their email", user.ID))
+
+		responsePayload.Data.ID = user.ID
+		responsePayload.Message = "Email changed"
+		app.writeJSON(w, http.StatusOK, responsePayload)
+		return
+	}
+
+	token, err := app.Models.EmailVerification.CreateForEmailChange(user.ID, newEmail, app.EmailVerificationTTL)
+	if err != nil {
+		log.Printf("Error creating email change token for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error changing email"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.Mailer.Send(mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your new email address with the link below. It expires in %s.\n\n%s?token=%s\n",
+			user.FullName, app.EmailVerificationTTL, app.EmailVerificationURL, url.QueryEscape(token)),
+	})
+	if err != nil {
+		log.Printf("Error sending email change link for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error changing email"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("Hi %s,\n\nA change of your account's email address to %s was requested. If this wasn't you, change your password right away.\n",
+			user.FullName, newEmail),
+	})
+	if err != nil {
+		log.Printf("Error sending email change notice for id: %v: %v\n", user.ID, err)
+	}
+
+	app.audit(r.Context(), user.ID, "Auth_EmailChangeRequested", fmt.Sprintf("User with id: %v requested an email change", user.ID))
+
+	responsePayload.Data.ID = user.ID
+	responsePayload.Message = "A confirmation link has been sent to the new email address"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// changePassword sets a new password after re-authenticating the user, which is also
+// how users without a password set their first one. Every other session is ended, and
+// the caller gets a fresh refresh token to stay logged in.
+func (app *Config) changePassword(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	if a.NewPassword == "" {
+		app.errorJSON(w, errors.New("new password is required"))
+		return
+	}
+
+	if err := app.PasswordPolicy.Check(a.NewPassword, user.Email); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if _, ok := app.reauthenticate(w, r, user, a); !ok {
+		return
+	}
+
+	err := app.Models.User.ChangePassword(user.ID, a.NewPassword)
+	if err != nil {
+		log.Printf("Error changing password for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error changing password"), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r.Context(), user.ID, "Auth_PasswordChanged", fmt.Sprintf("User with id: %v changed their password", user.ID))
+
+	refreshToken, err := app.Models.RefreshToken.Issue(user.ID, app.RefreshTokenTTL)
+	if err != nil {
+		log.Printf("Error issuing refresh token for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error issuing refresh token"), http.StatusInternalServerError)
+		return
+	}
+
+	responsePayload.Data.ID = user.ID
+	responsePayload.Data.Role = user.Role
+	responsePayload.Data.RefreshToken = refreshToken
+	responsePayload.Message = "Password changed"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// reauthenticate checks the user with a.Password before a sensitive change. Failures
+// count against the same lockout as failed logins, so a stolen access token can't be
+// used to guess the password. Users without a password are checked with
+// reauthenticateWithoutPassword instead.
+func (app *Config) reauthenticate(w http.ResponseWriter, r *http.Request, user *data.User, a AuthPayload) (reauthentication, bool) {
+	if !user.HasPassword() {
+		return app.reauthenticateWithoutPassword(w, r, user, a)
+	}
+
+	attempt := AuthPayload{Email: user.Email, ClientIP: a.ClientIP}
+
+	if app.loginLocked(w, attempt) {
+		return 0, false
+	}
+
+	valid, err := user.PasswordMatches(a.Password)
+	if err != nil || !valid {
+		app.recordLoginFailure(r.Context(), attempt)
+		app.errorJSON(w, errWrongPassword, http.StatusUnauthorized)
+		return 0, false
+	}
+
+	app.resetLoginFailures(attempt)
+	return reauthPassword, true
+}
+
+// reauthenticateWithoutPassword checks a user who only logs in with an external
+// provider, either with a fresh login at a provider linked to the account, started
+// with oidc_start and passed back in a.State and a.AuthorizationCode, or with a
+// two-factor code in a.Code. Wrong codes count against the login lockout, as wrong
+// passwords do.
+func (app *Config) reauthenticateWithoutPassword(w http.ResponseWriter, r *http.Request, user *data.User, a AuthPayload) (reauthentication, bool) {
+	switch {
+	case a.State != "":
+		providerName, claims, err := app.providerLogin(a)
+		if err != nil {
+			if !errors.Is(err, data.ErrOIDCStateInvalid) && !errors.Is(err, errProviderLogin) && !errors.Is(err, errUnknownProvider) {
+				log.Printf("Error consuming login state: %v\n", err)
+			}
+			app.errorJSON(w, errProviderLogin, http.StatusUnauthorized)
+			return 0, false
+		}
+
+		userID, err := app.Models.UserIdentity.FindUser(providerName, claims.Subject)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error finding user for %v subject: %v: %v\n", providerName, claims.Subject, err)
+		}
+		if err != nil || userID != user.ID {
+			app.errorJSON(w, errProviderLogin, http.StatusUnauthorized)
+			return 0, false
+		}
+
+		return reauthProvider, true
+
+	case a.Code != "":
+		if !app.verifySecondFactor(w, r, user, a) {
+			return 0, false
+		}
+
+		return reauthSecondFactor, true
+	}
+
+	app.errorJSON(w, errReauthRequired, http.StatusUnauthorized)
+	return 0, false
+}
+
+// audit logs a change to an account and records it with the logger service. The
+// entries outlive the account, so msg must not hold personal data such as names or
+// emails; the user ID is enough to tell whose account it was.
+func (app *Config) audit(ctx context.Context, userID, name, msg string) {
+	log.Print(msg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      name,
+		Data:      msg,
+		UserID:    userID,
+		RequestID: requestid.FromContext(ctx),
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"authentication/data"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"proto/requestid"
+	"strings"
+)
+
+var errForbidden = errors.New("forbidden")
+
+// setRole lets an admin change the role of another user, identified by email. The new
+// role reaches the user's access token on their next refresh. The caller must be an
+// admin both in their verified access token and in the stored user, so a demoted admin
+// loses the right before their token expires.
+func (app *Config) setRole(w http.ResponseWriter, r *http.Request, a AuthPayload, caller Caller) {
+	var responsePayload jsonResponse
+
+	admin, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	if caller.Role != data.RoleAdmin || admin.Role != data.RoleAdmin {
+		log.Printf("User with id: %v is not allowed to change roles\n", admin.ID)
+		app.errorJSON(w, errForbidden, http.StatusForbidden)
+		return
+	}
+
+	userID, err := app.Models.User.SetRole(a.Email, a.Role)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidRole) {
+			app.errorJSON(w, err)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			app.errorJSON(w, errors.New("user not found"), http.StatusNotFound)
+			return
+		}
+		log.Printf("Error setting role for %v: %v\n", a.Email, err)
+		app.errorJSON(w, fmt.Errorf("error setting role"), http.StatusInternalServerError)
+		return
+	}
+
+	roleMsg := fmt.Sprintf("User with id: %v given role: %v by admin with id: %v", userID, a.Role, admin.ID)
+	log.Print(roleMsg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      "Auth_RoleChanged",
+		Data:      roleMsg,
+		UserID:    userID,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+
+	responsePayload.Data.ID = userID
+	responsePayload.Data.Role = a.Role
+	responsePayload.Message = "Role updated"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// bootstrapAdmins gives the admin role to the users listed in ADMIN_EMAILS, so the
+// first admin can be created without touching the database. Unknown emails are skipped.
+func (app *Config) bootstrapAdmins(emails string) {
+	for _, email := range strings.Split(emails, ",") {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+
+		userID, err := app.Models.User.SetRole(email, data.RoleAdmin)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error making %v an admin: %v\n", email, err)
+			}
+			continue
+		}
+
+		log.Printf("User with id: %v is an admin\n", userID)
+	}
+}
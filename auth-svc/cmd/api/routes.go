@@ -2,20 +2,27 @@ package main
 
 import (
 	"net/http"
+	"proto/requestid"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func (app *Config) routes() http.Handler {
 	mux := chi.NewRouter()
 
+	// continue the trace of the broker's request from its traceparent header
+	mux.Use(otelhttp.NewMiddleware("auth-svc", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	})))
+
 	mux.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Request-Id"},
 		AllowCredentials: true,
 		ExposedHeaders:   []string{"Link"},
 		MaxAge:           300,
@@ -23,7 +30,22 @@ func (app *Config) routes() http.Handler {
 
 	mux.Use(middleware.Heartbeat("/health"))
 
+	mux.Use(withRequestID)
+
 	mux.Post("/auth", app.Authenticate)
 
 	return mux
 }
+
+// withRequestID puts the request ID the broker sent in the request's context, so the
+// log entries of the request can be found by it, and echoes it in the response
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(requestid.Header); requestid.Valid(id) {
+			w.Header().Set(requestid.Header, id)
+			r = r.WithContext(requestid.NewContext(r.Context(), id))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// loadRPCTLS reads the certificate, key and CA named by RPC_TLS_CERT, RPC_TLS_KEY and
+// RPC_TLS_CA (see project/gen-certs.sh). The server config serves the HTTP port over
+// mutual TLS to the services listed in allowedClients, a comma separated list of
+// service names; if empty, any certificate from the CA is accepted. The client config
+// calls the logger service's gRPC port, which only accepts clients with a certificate
+// from the CA.
+func loadRPCTLS(allowedClients string) (server *tls.Config, client *tls.Config, err error) {
+	certFile, keyFile, caFile := os.Getenv("RPC_TLS_CERT"), os.Getenv("RPC_TLS_KEY"), os.Getenv("RPC_TLS_CA")
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, nil, errors.New("RPC_TLS_CERT, RPC_TLS_KEY and RPC_TLS_CA are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(allowedClients, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+
+	server = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(allowed) == 0 {
+				return nil
+			}
+			if name := peerName(cs.PeerCertificates[0]); !allowed[name] {
+				return fmt.Errorf("client %q is not allowed", name)
+			}
+			return nil
+		},
+	}
+
+	client = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS13,
+	}
+
+	return server, client, nil
+}
+
+// peerName is the service name a certificate was issued to
+func peerName(cert *x509.Certificate) string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
+
+// brokerName is the service name of the broker's certificate
+const brokerName = "broker-svc"
+
+// clientIP returns the address throttling is keyed on: claimed, the address the
+// broker received the request from, if the request comes from the broker over mutual
+// TLS, and otherwise the address of the connection itself
+func clientIP(r *http.Request, claimed string) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && peerName(r.TLS.PeerCertificates[0]) == brokerName {
+		if ip := net.ParseIP(claimed); ip != nil {
+			return ip.String()
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"authentication/data"
+	"authentication/totp"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"proto/requestid"
+	"time"
+)
+
+// recoveryCodeCount is how many one-time recovery codes are handed out when two-factor
+// authentication is enabled
+const recoveryCodeCount = 10
+
+var errInvalidCode = errors.New("invalid two-factor code")
+
+// enrollTwoFactor starts enrollment with a fresh secret. Two-factor stays off until
+// the user proves their app works with 2fa_confirm.
+func (app *Config) enrollTwoFactor(w http.ResponseWriter, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Error generating totp secret: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error enrolling two-factor authentication"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.Models.TwoFactor.Enroll(user.ID, secret)
+	if err != nil {
+		if errors.Is(err, data.ErrTwoFactorEnabled) {
+			app.errorJSON(w, err)
+			return
+		}
+		log.Printf("Error enrolling two-factor for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error enrolling two-factor authentication"), http.StatusInternalServerError)
+		return
+	}
+
+	responsePayload.Data.Secret = secret
+	responsePayload.Data.OtpauthURI = totp.URI(app.TOTPIssuer, user.Email, secret)
+	responsePayload.Message = "Scan the code with an authenticator app and confirm with a code"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// confirmTwoFactor enables two-factor once a code from the enrolled secret checks out,
+// and returns the recovery codes. This is the only time they are shown.
+func (app *Config) confirmTwoFactor(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	tf, err := app.Models.TwoFactor.Get(user.ID)
+	if err != nil {
+		log.Printf("Error getting two-factor settings for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error confirming two-factor authentication"), http.StatusInternalServerError)
+		return
+	}
+
+	if tf.EnabledAt != nil {
+		app.errorJSON(w, data.ErrTwoFactorEnabled)
+		return
+	}
+	if tf.Secret == "" {
+		app.errorJSON(w, data.ErrTwoFactorNotEnrolled)
+		return
+	}
+
+	step, valid := totp.Validate(tf.Secret, a.Code, time.Now())
+	if !valid {
+		app.errorJSON(w, errInvalidCode)
+		return
+	}
+
+	if _, err = app.Models.TwoFactor.UseStep(user.ID, step); err != nil {
+		log.Printf("Error recording totp step for id: %v: %v\n", user.ID, err)
+	}
+
+	codes, err := data.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error confirming two-factor authentication"), http.StatusInternalServerError)
+		return
+	}
+
+	if err = app.Models.TwoFactor.Enable(user.ID, codes); err != nil {
+		log.Printf("Error enabling two-factor for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error confirming two-factor authentication"), http.StatusInternalServerError)
+		return
+	}
+
+	enabledMsg := fmt.Sprintf("User with id: %v enabled two-factor authentication", user.ID)
+	log.Print(enabledMsg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      "Auth_TwoFactorEnabled",
+		Data:      enabledMsg,
+		UserID:    user.ID,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+
+	responsePayload.Data.RecoveryCodes = codes
+	responsePayload.Message = "Two-factor authentication enabled, store the recovery codes somewhere safe"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// disableTwoFactor turns two-factor off. It needs both the password and a current code
+// or recovery code, so a stolen session alone can't remove it. Wrong passwords and
+// codes count against the login lockout.
+func (app *Config) disableTwoFactor(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var responsePayload jsonResponse
+
+	user, ok := app.requireUser(w, a)
+	if !ok {
+		return
+	}
+
+	if !user.HasPassword() {
+		app.errorJSON(w, fmt.Errorf("invalid credentials"), http.StatusUnauthorized)
+		return
+	}
+
+	// the code is checked before the password, since a correct password clears the
+	// lockout and would let wrong codes be tried without end
+	if !app.verifySecondFactor(w, r, user, a) {
+		return
+	}
+
+	if _, ok := app.reauthenticate(w, r, user, a); !ok {
+		return
+	}
+
+	if err := app.Models.TwoFactor.Disable(user.ID); err != nil {
+		log.Printf("Error disabling two-factor for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error disabling two-factor authentication"), http.StatusInternalServerError)
+		return
+	}
+
+	disabledMsg := fmt.Sprintf("User with id: %v disabled two-factor authentication", user.ID)
+	log.Print(disabledMsg)
+
+	app.logItemViaRPC(LogPayload{
+		Name:      "Auth_TwoFactorDisabled",
+		Data:      disabledMsg,
+		UserID:    user.ID,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+
+	responsePayload.Message = "Two-factor authentication disabled"
+	app.writeJSON(w, http.StatusOK, responsePayload)
+}
+
+// verifyTwoFactor exchanges a login challenge and a TOTP or recovery code for the
+// refresh token a plain login would have returned. Wrong codes count against the
+// account and IP lockout like wrong passwords, so logging in again for a fresh
+// challenge doesn't give more tries.
+func (app *Config) verifyTwoFactor(w http.ResponseWriter, r *http.Request, a AuthPayload) {
+	var user *data.User
+	attempt := AuthPayload{ClientIP: a.ClientIP}
+	valid, locked := false, false
+
+	userID, err := app.Models.LoginChallenge.Verify(a.ChallengeToken, func(userID string) (bool, error) {
+		u, err := app.Models.User.GetByID(userID)
+		if err != nil {
+			return false, err
+		}
+		user = u
+		attempt.Email = u.Email
+
+		// the account is only known from the challenge, so the lockout is checked here
+		if app.loginLocked(w, attempt) {
+			locked = true
+			return false, nil
+		}
+
+		ok, err := app.checkSecondFactor(userID, a.Code)
+		valid = ok
+		return ok, err
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrChallengeInvalid) {
+			app.errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Error verifying login challenge: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("error verifying two-factor code"), http.StatusInternalServerError)
+		return
+	}
+
+	if locked {
+		return
+	}
+
+	if !valid {
+		log.Printf("Invalid two-factor code for id: %v\n", userID)
+		app.recordLoginFailure(r.Context(), attempt)
+		app.errorJSON(w, errInvalidCode, http.StatusUnauthorized)
+		return
+	}
+
+	app.completeLogin(w, r, user, fmt.Sprintf("User with id: %v logged in with two-factor authentication", userID))
+}
+
+// verifySecondFactor checks a.Code as the user's second factor outside of a login,
+// counting a wrong code against the login lockout like a wrong password. It writes the
+// error response and returns false if the code isn't accepted.
+func (app *Config) verifySecondFactor(w http.ResponseWriter, r *http.Request, user *data.User, a AuthPayload) bool {
+	attempt := AuthPayload{Email: user.Email, ClientIP: a.ClientIP}
+
+	if app.loginLocked(w, attempt) {
+		return false
+	}
+
+	valid, err := app.checkSecondFactor(user.ID, a.Code)
+	if err != nil {
+		log.Printf("Error checking two-factor code for id: %v: %v\n", user.ID, err)
+		app.errorJSON(w, fmt.Errorf("error checking two-factor code"), http.StatusInternalServerError)
+		return false
+	}
+	if !valid {
+		app.recordLoginFailure(r.Context(), attempt)
+		app.errorJSON(w, errInvalidCode, http.StatusUnauthorized)
+		return false
+	}
+
+	app.resetLoginFailures(attempt)
+	return true
+}
+
+// checkSecondFactor accepts either an unused TOTP code or an unused recovery code
+func (app *Config) checkSecondFactor(userID, code string) (bool, error) {
+	tf, err := app.Models.TwoFactor.Get(userID)
+	if err != nil {
+		return false, err
+	}
+
+	if tf.EnabledAt == nil {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(tf.Secret, code, time.Now()); ok {
+		return app.Models.TwoFactor.UseStep(userID, step)
+	}
+
+	return app.Models.TwoFactor.UseRecoveryCode(userID, code)
+}
+
+// requireUser loads the user of the request's access token, in a.UserID, writing an
+// error response if there is none
+func (app *Config) requireUser(w http.ResponseWriter, a AuthPayload) (*data.User, bool) {
+	if a.UserID == "" {
+		app.errorJSON(w, errUnauthorized, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	user, err := app.Models.User.GetByID(a.UserID)
+	if err != nil {
+		log.Printf("Error getting user with id: %v: %v\n", a.UserID, err)
+		app.errorJSON(w, errUnauthorized, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrVerificationTokenInvalid = errors.New("invalid or expired verification token")
+
+// EmailVerification is one single use email verification token from the database
+type EmailVerification struct {
+	ID        int       `json:"id"`
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Create stores a new verification token for the user and returns it in plain text.
+// Earlier tokens stay valid until they expire, so a resend doesn't break a link the
+// user is about to click.
+func (e *EmailVerification) Create(userID string, ttl time.Duration) (string, error) {
+	return e.create(userID, nil, ttl)
+}
+
+// CreateForEmailChange stores a token that, once consumed, changes the user's email
+// to newEmail. The current email stays in use until then.
+func (e *EmailVerification) CreateForEmailChange(userID string, newEmail string, ttl time.Duration) (string, error) {
+	newEmail = NormalizeEmail(newEmail)
+	return e.create(userID, &newEmail, ttl)
+}
+
+func (e *EmailVerification) create(userID string, newEmail *string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	plainText, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `insert into email_verification_tokens (user_id, token_hash, expires_at, new_email)
+		values ($1, $2, $3, $4)`
+
+	_, err = db.ExecContext(ctx, stmt, userID, hashToken(plainText), time.Now().Add(ttl), newEmail)
+	if err != nil {
+		return "", err
+	}
+
+	return plainText, nil
+}
+
+// Consume marks the token as used, marks its user as verified and returns the user ID.
+// For an email change token the user's email is replaced as well, and the new email is
+// returned; it is empty otherwise.
+func (e *EmailVerification) Consume(plainText string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	var newEmail sql.NullString
+	stmt := `update email_verification_tokens set used_at = now()
+		where token_hash = $1 and used_at is null and expires_at > now()
+		returning user_id, new_email`
+
+	err = tx.QueryRowContext(ctx, stmt, hashToken(plainText)).Scan(&userID, &newEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrVerificationTokenInvalid
+		}
+		return "", "", err
+	}
+
+	if newEmail.Valid {
+		_, err = tx.ExecContext(ctx, `update users set email = $2, verified_at = now() where id = $1`, userID, newEmail.String)
+		if isUniqueViolation(err) {
+			return "", "", ErrEmailTaken
+		}
+	} else {
+		_, err = tx.ExecContext(ctx, `update users set verified_at = now() where id = $1 and verified_at is null`, userID)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `update email_verification_tokens set used_at = now()
+		where user_id = $1 and used_at is null`, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return userID, newEmail.String, nil
+}
+
+// CountSince returns how many tokens were created for the user since the given time,
+// and when the latest one was created
+func (e *EmailVerification) CountSince(userID string, since time.Time) (int, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var count int
+	var latest sql.NullTime
+	query := `select count(*), max(created_at) from email_verification_tokens
+		where user_id = $1 and created_at > $2`
+
+	err := db.QueryRowContext(ctx, query, userID, since).Scan(&count, &latest)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return count, latest.Time, nil
+}
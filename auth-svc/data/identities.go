@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrOIDCStateInvalid = errors.New("invalid or expired login state")
+
+// UserIdentity links a user to a subject at an external OpenID provider
+type UserIdentity struct {
+	ID          int        `json:"id"`
+	UserID      string     `json:"userId"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
+
+// OIDCState is a pending authorization request, waiting for the provider's callback
+type OIDCState struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+}
+
+// FindUser returns the ID of the user linked to the provider's subject, and records
+// the login. It returns sql.ErrNoRows if the subject is not linked to anyone.
+func (i *UserIdentity) FindUser(provider, subject string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var userID string
+	stmt := `update user_identities set last_login_at = now()
+		where provider = $1 and subject = $2
+		returning user_id`
+
+	err := db.QueryRowContext(ctx, stmt, provider, subject).Scan(&userID)
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// Link links the provider's subject to an existing user
+func (i *UserIdentity) Link(userID, provider, subject, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	stmt := `insert into user_identities (user_id, provider, subject, email, last_login_at)
+		values ($1, $2, $3, $4, now())`
+
+	_, err := db.ExecContext(ctx, stmt, userID, provider, subject, email)
+	return err
+}
+
+// CreateUser inserts a new user without a password, linked to the provider's subject,
+// and returns the ID of the new user. The user can set a password later with a
+// password reset.
+func (i *UserIdentity) CreateUser(user User, provider, subject string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRowContext(ctx, `insert into users (email, password, full_name, verified_at)
+		values ($1, '', $2, $3) returning id`,
+		NormalizeEmail(user.Email),
+		user.FullName,
+		user.VerifiedAt,
+	).Scan(&userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return "", ErrEmailTaken
+		}
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into user_identities (user_id, provider, subject, email, last_login_at)
+		values ($1, $2, $3, $4, now())`, userID, provider, subject, user.Email)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// Create stores a pending authorization request and returns its state parameter in
+// plain text. Expired requests are cleaned up on the way.
+func (s *OIDCState) Create(provider, nonce, codeVerifier string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	plainText, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.ExecContext(ctx, `delete from oidc_login_states where expires_at < now()`)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `insert into oidc_login_states (state_hash, provider, nonce, code_verifier, expires_at)
+		values ($1, $2, $3, $4, $5)`
+
+	_, err = db.ExecContext(ctx, stmt, hashToken(plainText), provider, nonce, codeVerifier, time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return plainText, nil
+}
+
+// Consume removes a pending authorization request and returns it. Unknown and expired
+// states give ErrOIDCStateInvalid.
+func (s *OIDCState) Consume(plainText string) (*OIDCState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var state OIDCState
+	stmt := `delete from oidc_login_states
+		where state_hash = $1 and expires_at > now()
+		returning provider, nonce, code_verifier`
+
+	err := db.QueryRowContext(ctx, stmt, hashToken(plainText)).Scan(&state.Provider, &state.Nonce, &state.CodeVerifier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOIDCStateInvalid
+		}
+		return nil, err
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ThrottlePolicy says how failed logins against one key (an account or an IP) are
+// punished. The first FreeFailures failures within Window cost nothing, after that
+// every failure locks the key for BaseLockout, doubling each time up to MaxLockout.
+type ThrottlePolicy struct {
+	FreeFailures int
+	BaseLockout  time.Duration
+	MaxLockout   time.Duration
+	Window       time.Duration
+}
+
+// Lockout returns how long a key is locked after its nth failure
+func (p ThrottlePolicy) Lockout(failures int) time.Duration {
+	if failures <= p.FreeFailures {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for i := p.FreeFailures + 1; i < failures; i++ {
+		lockout *= 2
+		if lockout >= p.MaxLockout {
+			return p.MaxLockout
+		}
+	}
+
+	return lockout
+}
+
+const (
+	accountThrottlePrefix = "account:"
+	ipThrottlePrefix      = "ip:"
+)
+
+// AccountThrottleKey returns the key failed logins against the account with the given
+// email are counted under
+func AccountThrottleKey(email string) string {
+	return accountThrottlePrefix + NormalizeEmail(email)
+}
+
+// IPThrottleKey returns the key failed logins from the given client IP are counted
+// under, or an empty string if the IP is unknown
+func IPThrottleKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return ipThrottlePrefix + ip
+}
+
+// LoginThrottle counts failed logins per key
+type LoginThrottle struct {
+	Key         string     `json:"key"`
+	Failures    int        `json:"failures"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// Locked returns when the key's current lockout ends, or the zero time if it isn't
+// locked
+func (t *LoginThrottle) Locked(key string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var lockedUntil sql.NullTime
+	query := `select locked_until from login_throttles where throttle_key = $1 and locked_until > now()`
+
+	err := db.QueryRowContext(ctx, query, key).Scan(&lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	return lockedUntil.Time, nil
+}
+
+// RecordFailure counts a failed login against the key and locks it if the policy says
+// so. It returns the end of the new lockout, or the zero time if the key isn't locked.
+func (t *LoginThrottle) RecordFailure(key string, policy ThrottlePolicy) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var failures int
+	stmt := `insert into login_throttles (throttle_key, failures, last_failure_at)
+		values ($1, 1, now())
+		on conflict (throttle_key) do update set
+			failures = case
+				when login_throttles.last_failure_at < now() - make_interval(secs => $2) then 1
+				else login_throttles.failures + 1
+			end,
+			last_failure_at = now()
+		returning failures`
+
+	err := db.QueryRowContext(ctx, stmt, key, policy.Window.Seconds()).Scan(&failures)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	lockout := policy.Lockout(failures)
+	if lockout == 0 {
+		return time.Time{}, nil
+	}
+
+	lockedUntil := time.Now().Add(lockout)
+
+	_, err = db.ExecContext(ctx, `update login_throttles set locked_until = $1 where throttle_key = $2`, lockedUntil, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil, nil
+}
+
+// Reset forgets the failures of a key, after a successful login
+func (t *LoginThrottle) Reset(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `delete from login_throttles where throttle_key = $1`, key)
+	return err
+}
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- set when the token confirms an email change rather than the signup email
+ALTER TABLE email_verification_tokens ADD COLUMN new_email VARCHAR(50);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE email_verification_tokens DROP COLUMN new_email;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+UPDATE users SET email = lower(trim(email)) WHERE email <> lower(trim(email));
+CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_email_lower_key;
+-- +goose StatementEnd
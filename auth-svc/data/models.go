@@ -5,22 +5,40 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"proto/telemetry"
+	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
 )
 
 const dbTimeout = time.Second * 3
 
+// tracer traces the queries that sit on the login and signup path
+var tracer = otel.Tracer("authentication/data")
+
 var db *sql.DB
 
+// passwords hashes the passwords of all users
+var passwords = DefaultPasswordHasher()
+
 // New is the function used to create an instance of the data package. It returns the type
 // Model, which embeds all the types we want to be available to our application.
-func New(dbPool *sql.DB) Models {
+func New(dbPool *sql.DB, hasher PasswordHasher) Models {
 	db = dbPool
+	passwords = hasher
 
 	return Models{
-		User: User{},
+		User:              User{},
+		RefreshToken:      RefreshToken{},
+		PasswordReset:     PasswordReset{},
+		EmailVerification: EmailVerification{},
+		TwoFactor:         TwoFactor{},
+		LoginChallenge:    LoginChallenge{},
+		LoginThrottle:     LoginThrottle{},
+		UserIdentity:      UserIdentity{},
+		OIDCState:         OIDCState{},
 	}
 }
 
@@ -28,33 +46,105 @@ func New(dbPool *sql.DB) Models {
 // in this type is available to us throughout the application, anywhere that the
 // app variable is used, provided that the model is also added in the New function.
 type Models struct {
-	User User
+	User              User
+	RefreshToken      RefreshToken
+	PasswordReset     PasswordReset
+	EmailVerification EmailVerification
+	TwoFactor         TwoFactor
+	LoginChallenge    LoginChallenge
+	LoginThrottle     LoginThrottle
+	UserIdentity      UserIdentity
+	OIDCState         OIDCState
+}
+
+// roles a user can have. Staff manage the reservations of the restaurants they are
+// assigned to in reservation-svc; admins manage everything.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "restaurant_staff"
+	RoleAdmin    = "admin"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	ErrEmailTaken  = errors.New("email address is already in use")
+)
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleStaff, RoleAdmin:
+		return true
+	}
+	return false
 }
 
 // User is the structure which holds one user from the database.
 type User struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	FullName  string    `json:"fullName"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string     `json:"id"`
+	Email      string     `json:"email"`
+	FullName   string     `json:"fullName"`
+	Password   string     `json:"password"`
+	Role       string     `json:"role"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NormalizeEmail returns email the way it is stored: trimmed and lowercased. Every
+// query by email goes through it, so lookups, the unique constraint and the login
+// throttle agree on which account an address names.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // GetByEmail returns one user by email
-func (u *User) GetByEmail(email string) (*User, error) {
+func (u *User) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `select id, email, password, full_name, role, verified_at, created_at from users where email = $1 and deleted_at is null`
+
+	ctx, span := telemetry.StartQuery(ctx, tracer, "SELECT", "users", query)
+
+	var user User
+	row := db.QueryRowContext(ctx, query, NormalizeEmail(email))
+
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Password,
+		&user.FullName,
+		&user.Role,
+		&user.VerifiedAt,
+		&user.CreatedAt,
+	)
+	telemetry.End(span, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetByID returns one user by id
+func (u *User) GetByID(id string) (*User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
-	query := `select id, email, password, full_name from users where email = $1`
+	query := `select id, email, password, full_name, role, verified_at, created_at from users where id = $1 and deleted_at is null`
 
 	var user User
-	row := db.QueryRowContext(ctx, query, email)
+	row := db.QueryRowContext(ctx, query, id)
 
 	err := row.Scan(
 		&user.ID,
 		&user.Email,
 		&user.Password,
 		&user.FullName,
+		&user.Role,
+		&user.VerifiedAt,
+		&user.CreatedAt,
 	)
 
 	if err != nil {
@@ -65,24 +155,28 @@ func (u *User) GetByEmail(email string) (*User, error) {
 }
 
 // Insert inserts a new user into the database, and returns the ID of the newly inserted row
-func (u *User) Insert(user User) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (u *User) Insert(ctx context.Context, user User) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), 12)
+	hashedPassword, err := passwords.Hash(user.Password)
 	if err != nil {
 		return 0, err
 	}
 
 	var newID int
-	stmt := `insert into users (email, password, full_name)
-		values ($1, $2, $3) returning id`
+	stmt := `insert into users (email, password, full_name, verified_at)
+		values ($1, $2, $3, $4) returning id`
+
+	ctx, span := telemetry.StartQuery(ctx, tracer, "INSERT", "users", stmt)
 
 	err = db.QueryRowContext(ctx, stmt,
-		user.Email,
+		NormalizeEmail(user.Email),
 		hashedPassword,
 		user.FullName,
+		user.VerifiedAt,
 	).Scan(&newID)
+	telemetry.End(span, err)
 
 	if err != nil {
 		return 0, err
@@ -91,20 +185,234 @@ func (u *User) Insert(user User) (int, error) {
 	return newID, nil
 }
 
-// PasswordMatches uses Go's bcrypt package to compare a user supplied password
-// with the hash we have stored for a given user in the database. If the password
-// and hash match, we return true; otherwise, we return false.
-func (u *User) PasswordMatches(plainText string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(plainText))
-	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			// invalid password
-			return false, fmt.Errorf("invalid credentials")
-		default:
-			return false, err
+// ChangePassword hashes and stores a new password for the user with the given ID and
+// revokes all of their refresh tokens, in one transaction, so the password never
+// changes while old sessions stay valid
+func (u *User) ChangePassword(id string, plainText string) error {
+	hashedPassword, err := passwords.Hash(plainText)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update users set password = $1 where id = $2`, hashedPassword, id)
+	if err != nil {
+		return err
+	}
+
+	if err = revokeAllForUser(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RehashPassword stores a new hash of the user's password if the current one was made
+// with an outdated algorithm or parameters. plainText must already have been checked
+// with PasswordMatches. It reports whether the hash was replaced.
+func (u *User) RehashPassword(plainText string) (bool, error) {
+	if !passwords.NeedsRehash(u.Password) {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	hashedPassword, err := passwords.Hash(plainText)
+	if err != nil {
+		return false, err
+	}
+
+	// only replace the hash that was checked, in case the password changed meanwhile
+	stmt := `update users set password = $1 where id = $2 and password = $3`
+
+	result, err := db.ExecContext(ctx, stmt, hashedPassword, u.ID, u.Password)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 1 {
+		u.Password = hashedPassword
+	}
+
+	return rows == 1, nil
+}
+
+// UpdateFullName changes the full name of the user with the given ID
+func (u *User) UpdateFullName(id string, fullName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	stmt := `update users set full_name = $1 where id = $2`
+
+	_, err := db.ExecContext(ctx, stmt, fullName, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateEmail changes the email of the user with the given ID right away, for when
+// email verification is disabled
+func (u *User) UpdateEmail(id string, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	stmt := `update users set email = $1 where id = $2`
+
+	_, err := db.ExecContext(ctx, stmt, NormalizeEmail(email), id)
+	if isUniqueViolation(err) {
+		return ErrEmailTaken
+	}
+
+	return err
+}
+
+// ClaimUnverified marks an unverified user as verified and removes their password,
+// for when the owner of the email proves it through an external provider
+func (u *User) ClaimUnverified(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	stmt := `update users set password = '', verified_at = now() where id = $1 and verified_at is null`
+
+	_, err := db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `delete from refresh_tokens where user_id = $1`, id)
+	return err
+}
+
+// SoftDelete marks the user with the given ID as deleted and strips the row of
+// personal data. The row itself is kept so records that reference it, such as past
+// reservations, stay intact. Sessions, pending tokens and the failed login count of
+// the account, which is keyed by its email, are removed.
+func (u *User) SoftDelete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRowContext(ctx, `select email from users where id = $1 and deleted_at is null for update`, id).Scan(&email)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from login_throttles where throttle_key = $1`, AccountThrottleKey(email))
+	if err != nil {
+		return err
+	}
+
+	stmt := `update users set
+			email = 'deleted-' || id || '@deleted.invalid',
+			full_name = 'Deleted user',
+			password = '',
+			role = $2,
+			totp_secret = null,
+			totp_enabled_at = null,
+			totp_last_step = null,
+			deleted_at = now()
+		where id = $1 and deleted_at is null`
+
+	result, err := tx.ExecContext(ctx, stmt, id, RoleCustomer)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	for _, table := range []string{
+		"refresh_tokens",
+		"password_reset_tokens",
+		"email_verification_tokens",
+		"recovery_codes",
+		"login_challenges",
+		"user_identities",
+	} {
+		if _, err = tx.ExecContext(ctx, `delete from `+table+` where user_id = $1`, id); err != nil {
+			return err
 		}
 	}
 
+	return tx.Commit()
+}
+
+// SetRole changes the role of the user with the given email, and returns the user's ID
+func (u *User) SetRole(email string, role string) (string, error) {
+	if !ValidRole(role) {
+		return "", ErrInvalidRole
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var id string
+	stmt := `update users set role = $1 where email = $2 and deleted_at is null returning id`
+
+	err := db.QueryRowContext(ctx, stmt, role, NormalizeEmail(email)).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// HasPassword reports whether the user has a password. Users created through an
+// external provider, and unverified accounts claimed through one, have none.
+func (u *User) HasPassword() bool {
+	return u.Password != ""
+}
+
+// PasswordMatches compares a user supplied password with the hash we have stored for
+// a given user in the database, whichever algorithm made it. If the password and hash
+// match, we return true; otherwise, we return false.
+func (u *User) PasswordMatches(plainText string) (bool, error) {
+	if !u.HasPassword() {
+		return false, fmt.Errorf("invalid credentials")
+	}
+
+	valid, err := passwords.Verify(u.Password, plainText)
+	if err != nil {
+		return false, err
+	}
+
+	if !valid {
+		// invalid password
+		return false, fmt.Errorf("invalid credentials")
+	}
+
 	return true, nil
 }
+
+// isUniqueViolation reports whether err is a postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultPasswordMinLength = 8
+
+	// maxPasswordBytes is the most bcrypt can hash; longer passwords are refused
+	// whatever the algorithm, so switching back to bcrypt stays possible
+	maxPasswordBytes = 72
+)
+
+var ErrWeakPassword = errors.New("password does not meet the requirements")
+
+//go:embed breached_passwords.txt
+var breachedPasswords string
+
+// PasswordPolicy is checked whenever a password is set: at signup, on a password
+// change and on a reset. Existing passwords are not affected.
+type PasswordPolicy struct {
+	MinLength int
+
+	breached map[string]struct{}
+}
+
+// NewPasswordPolicy returns a policy requiring minLength characters and refusing the
+// passwords of the built in breached list, plus those in the file at breachedFile,
+// one per line, if it is set
+func NewPasswordPolicy(minLength int, breachedFile string) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{
+		MinLength: minLength,
+		breached:  map[string]struct{}{},
+	}
+
+	if err := p.addBreached(strings.NewReader(breachedPasswords)); err != nil {
+		return nil, err
+	}
+
+	if breachedFile != "" {
+		f, err := os.Open(breachedFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if err = p.addBreached(f); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", breachedFile, err)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *PasswordPolicy) addBreached(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+// BreachedCount returns how many passwords are on the breached list
+func (p *PasswordPolicy) BreachedCount() int {
+	return len(p.breached)
+}
+
+// Check returns an error wrapping ErrWeakPassword, with a message for the user, if
+// password doesn't meet the policy. email is refused as a password too.
+func (p *PasswordPolicy) Check(password, email string) error {
+	switch {
+	case utf8.RuneCountInString(password) < p.MinLength:
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrWeakPassword, p.MinLength)
+	case len(password) > maxPasswordBytes:
+		return fmt.Errorf("%w: it must be at most %d bytes long", ErrWeakPassword, maxPasswordBytes)
+	case email != "" && strings.EqualFold(password, email):
+		return fmt.Errorf("%w: it must not be your email address", ErrWeakPassword)
+	}
+
+	if _, found := p.breached[strings.ToLower(password)]; found {
+		return fmt.Errorf("%w: it appears in a list of breached passwords, choose another one", ErrWeakPassword)
+	}
+
+	return nil
+}
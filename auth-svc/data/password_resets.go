@@ -0,0 +1,103 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// PasswordReset is one single use password reset token from the database
+type PasswordReset struct {
+	ID        int       `json:"id"`
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Create invalidates the user's outstanding reset tokens, stores a new one and returns
+// it in plain text
+func (p *PasswordReset) Create(userID string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	plainText, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update password_reset_tokens set used_at = now()
+		where user_id = $1 and used_at is null`, userID)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `insert into password_reset_tokens (user_id, token_hash, expires_at)
+		values ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, stmt, userID, hashToken(plainText), time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return plainText, nil
+}
+
+// ResetPassword uses up a reset token, sets the password of the user it was issued to
+// and revokes all of their refresh tokens, in one transaction, so a failure leaves the
+// token usable and no session outlives a successful reset. It returns the user's ID. Unknown, expired and already used tokens give ErrResetTokenInvalid.
+func (p *PasswordReset) ResetPassword(plainText, newPassword string) (string, error) {
+	hashedPassword, err := passwords.Hash(newPassword)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	stmt := `update password_reset_tokens set used_at = now()
+		where token_hash = $1 and used_at is null and expires_at > now()
+		returning user_id`
+
+	err = tx.QueryRowContext(ctx, stmt, hashToken(plainText)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrResetTokenInvalid
+		}
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `update users set password = $1 where id = $2`, hashedPassword, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err = revokeAllForUser(ctx, tx, userID); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
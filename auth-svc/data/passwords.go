@@ -0,0 +1,196 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// password hashing algorithms. The algorithm and its parameters are stored with
+// every hash, so hashes made with older settings keep working and are upgraded the
+// next time the user logs in.
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+const (
+	DefaultBcryptCost = 12
+
+	// Argon2id defaults as recommended by OWASP
+	DefaultArgon2Memory      = 19 * 1024 // KiB
+	DefaultArgon2Iterations  = 2
+	DefaultArgon2Parallelism = 1
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	ErrUnknownHashAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash        = errors.New("malformed password hash")
+)
+
+// Argon2Params are the Argon2id cost parameters
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// PasswordHasher hashes new passwords with Algorithm, and verifies hashes made with
+// any supported algorithm
+type PasswordHasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// DefaultPasswordHasher returns a hasher using Argon2id with the default parameters
+func DefaultPasswordHasher() PasswordHasher {
+	return PasswordHasher{
+		Algorithm:  AlgorithmArgon2id,
+		BcryptCost: DefaultBcryptCost,
+		Argon2: Argon2Params{
+			Memory:      DefaultArgon2Memory,
+			Iterations:  DefaultArgon2Iterations,
+			Parallelism: DefaultArgon2Parallelism,
+		},
+	}
+}
+
+// Validate checks the hasher settings, so bad configuration fails at startup and not
+// on the first signup
+func (h PasswordHasher) Validate() error {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if h.Argon2.Memory < 8*uint32(h.Argon2.Parallelism) || h.Argon2.Iterations < 1 || h.Argon2.Parallelism < 1 {
+			return errors.New("argon2id needs at least 1 iteration, 1 thread and 8 KiB of memory per thread")
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, h.Algorithm)
+	}
+
+	return nil
+}
+
+// Hash hashes a password with the configured algorithm
+func (h PasswordHasher) Hash(plainText string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(plainText), h.BcryptCost)
+		return string(hash), err
+
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		p := h.Argon2
+		key := argon2.IDKey([]byte(plainText), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyLength)
+
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, h.Algorithm)
+}
+
+// Verify reports whether plainText matches hash, whichever supported algorithm made it.
+// An empty hash, as kept for accounts that only log in through a provider, never matches.
+func (h PasswordHasher) Verify(hash, plainText string) (bool, error) {
+	switch {
+	case hash == "":
+		return false, nil
+
+	case isBcryptHash(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plainText))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+
+	case strings.HasPrefix(hash, "$argon2id$"):
+		p, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(plainText), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	}
+
+	return false, ErrUnknownHashAlgorithm
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other parameters
+// than the configured ones
+func (h PasswordHasher) NeedsRehash(hash string) bool {
+	switch {
+	case hash == "":
+		return false
+
+	case isBcryptHash(hash):
+		if h.Algorithm != AlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.BcryptCost
+
+	case strings.HasPrefix(hash, "$argon2id$"):
+		if h.Algorithm != AlgorithmArgon2id {
+			return true
+		}
+		p, _, key, err := decodeArgon2id(hash)
+		return err != nil || p != h.Argon2 || len(key) != argon2KeyLength
+	}
+
+	return true
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// decodeArgon2id parses a hash in the PHC string format:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	return p, salt, key, nil
+}
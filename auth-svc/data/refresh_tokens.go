@@ -0,0 +1,168 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken is one refresh token from the database. Tokens issued by rotating
+// another token share its FamilyID, so a whole login session can be revoked at once.
+type RefreshToken struct {
+	ID        int       `json:"id"`
+	UserID    string    `json:"userId"`
+	FamilyID  string    `json:"familyId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Issue starts a new token family for the user and returns the plain text token
+func (t *RefreshToken) Issue(userID string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	familyID, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	return insertRefreshToken(ctx, db, userID, familyID, ttl)
+}
+
+// Rotate exchanges a refresh token for a new one in the same family and returns the
+// user ID and the new plain text token. Presenting a token that was already rotated
+// means it leaked, so the whole family is revoked and ErrRefreshTokenReused returned.
+func (t *RefreshToken) Rotate(plainText string, ttl time.Duration) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var userID, familyID string
+	var expiresAt time.Time
+	var rotatedAt, revokedAt sql.NullTime
+
+	query := `select id, user_id, family_id, expires_at, rotated_at, revoked_at
+		from refresh_tokens where token_hash = $1 for update`
+
+	err = tx.QueryRowContext(ctx, query, hashToken(plainText)).Scan(
+		&id,
+		&userID,
+		&familyID,
+		&expiresAt,
+		&rotatedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrRefreshTokenInvalid
+		}
+		return "", "", err
+	}
+
+	if revokedAt.Valid {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	if rotatedAt.Valid {
+		if err = revokeFamily(ctx, tx, familyID); err != nil {
+			return "", "", err
+		}
+		if err = tx.Commit(); err != nil {
+			return "", "", err
+		}
+		return userID, "", ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expiresAt) {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	_, err = tx.ExecContext(ctx, `update refresh_tokens set rotated_at = now() where id = $1`, id)
+	if err != nil {
+		return "", "", err
+	}
+
+	newToken, err := insertRefreshToken(ctx, tx, userID, familyID, ttl)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return userID, newToken, nil
+}
+
+// RevokeFamily revokes the token's whole family and returns the user ID it belonged to
+func (t *RefreshToken) RevokeFamily(plainText string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var userID, familyID string
+	query := `select user_id, family_id from refresh_tokens where token_hash = $1`
+
+	err := db.QueryRowContext(ctx, query, hashToken(plainText)).Scan(&userID, &familyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrRefreshTokenInvalid
+		}
+		return "", err
+	}
+
+	if err = revokeFamily(ctx, db, familyID); err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertRefreshToken(ctx context.Context, q execer, userID, familyID string, ttl time.Duration) (string, error) {
+	plainText, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `insert into refresh_tokens (user_id, family_id, token_hash, expires_at)
+		values ($1, $2, $3, $4)`
+
+	_, err = q.ExecContext(ctx, stmt, userID, familyID, hashToken(plainText), time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return plainText, nil
+}
+
+func revokeFamily(ctx context.Context, q execer, familyID string) error {
+	stmt := `update refresh_tokens set revoked_at = now() where family_id = $1 and revoked_at is null`
+
+	_, err := q.ExecContext(ctx, stmt, familyID)
+	return err
+}
+
+// revokeAllForUser revokes every refresh token of a user, ending all of their sessions
+func revokeAllForUser(ctx context.Context, q execer, userID string) error {
+	stmt := `update refresh_tokens set revoked_at = now() where user_id = $1 and revoked_at is null`
+
+	_, err := q.ExecContext(ctx, stmt, userID)
+	return err
+}
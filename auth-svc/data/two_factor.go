@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// maxChallengeAttempts is how many codes can be tried against one login challenge
+const maxChallengeAttempts = 5
+
+var (
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not set up")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrChallengeInvalid     = errors.New("invalid or expired login challenge")
+)
+
+// TwoFactor holds a user's TOTP settings. Secret is set once enrollment starts,
+// EnabledAt once it has been confirmed with a valid code.
+type TwoFactor struct {
+	UserID    string     `json:"userId"`
+	Secret    string     `json:"-"`
+	EnabledAt *time.Time `json:"enabledAt,omitempty"`
+	LastStep  int64      `json:"-"`
+}
+
+// Get returns the TOTP settings of a user
+func (t *TwoFactor) Get(userID string) (*TwoFactor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var tf TwoFactor
+	var secret sql.NullString
+	var lastStep sql.NullInt64
+
+	query := `select id, totp_secret, totp_enabled_at, totp_last_step from users where id = $1`
+
+	err := db.QueryRowContext(ctx, query, userID).Scan(&tf.UserID, &secret, &tf.EnabledAt, &lastStep)
+	if err != nil {
+		return nil, err
+	}
+
+	tf.Secret = secret.String
+	tf.LastStep = lastStep.Int64
+
+	return &tf, nil
+}
+
+// Enroll stores a new, not yet confirmed secret for the user
+func (t *TwoFactor) Enroll(userID, secret string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	stmt := `update users set totp_secret = $1, totp_last_step = null
+		where id = $2 and totp_enabled_at is null`
+
+	result, err := db.ExecContext(ctx, stmt, secret, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+// Enable turns on two-factor authentication and replaces the user's recovery codes
+func (t *TwoFactor) Enable(userID string, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update users set totp_enabled_at = now() where id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from recovery_codes where user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(ctx, `insert into recovery_codes (user_id, code_hash) values ($1, $2)`,
+			userID, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Disable turns off two-factor authentication and removes the secret and recovery codes
+func (t *TwoFactor) Disable(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update users set totp_secret = null, totp_enabled_at = null, totp_last_step = null
+		where id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from recovery_codes where user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records that a TOTP step was accepted. It returns false if that step, or a
+// later one, was already used, so an intercepted code can't be replayed.
+func (t *TwoFactor) UseStep(userID string, step int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	stmt := `update users set totp_last_step = $1
+		where id = $2 and (totp_last_step is null or totp_last_step < $1)`
+
+	result, err := db.ExecContext(ctx, stmt, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes, returning false if it
+// doesn't exist or was used before
+func (t *TwoFactor) UseRecoveryCode(userID, code string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	stmt := `update recovery_codes set used_at = now()
+		where user_id = $1 and code_hash = $2 and used_at is null`
+
+	result, err := db.ExecContext(ctx, stmt, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// NewRecoveryCodes returns n random recovery codes formatted as xxxxx-xxxxx
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(token))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// LoginChallenge is the short lived token handed out after a correct password when
+// the account has two-factor authentication enabled
+type LoginChallenge struct {
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Create stores a new challenge for the user and returns it in plain text
+func (c *LoginChallenge) Create(userID string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	plainText, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `insert into login_challenges (user_id, token_hash, expires_at) values ($1, $2, $3)`
+
+	_, err = db.ExecContext(ctx, stmt, userID, hashToken(plainText), time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return plainText, nil
+}
+
+// Verify looks up an open challenge, counts an attempt against it and asks check
+// whether the second factor is correct. On success the challenge is used up and the
+// user ID returned. A challenge allows maxChallengeAttempts tries.
+func (c *LoginChallenge) Verify(plainText string, check func(userID string) (bool, error)) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var id int
+	var userID string
+	stmt := `update login_challenges set attempts = attempts + 1
+		where token_hash = $1 and used_at is null and expires_at > now() and attempts < $2
+		returning id, user_id`
+
+	err := db.QueryRowContext(ctx, stmt, hashToken(plainText), maxChallengeAttempts).Scan(&id, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrChallengeInvalid
+		}
+		return "", err
+	}
+
+	ok, err := check(userID)
+	if err != nil || !ok {
+		return userID, err
+	}
+
+	result, err := db.ExecContext(ctx, `update login_challenges set used_at = now() where id = $1 and used_at is null`, id)
+	if err != nil {
+		return "", err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if affected == 0 {
+		return "", ErrChallengeInvalid
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,83 @@
+// Package mail sends the emails auth-svc needs, such as password reset links. The
+// Sender interface lets the delivery mechanism be swapped per environment.
+package mail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is one plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// New returns the sender named by kind: "stdout" (the default) prints messages, "file"
+// writes each message to its own file in dir.
+func New(kind, dir string) (Sender, error) {
+	switch kind {
+	case "", "stdout":
+		return &WriterSender{W: os.Stdout}, nil
+	case "file":
+		if dir == "" {
+			return nil, fmt.Errorf("mail directory is required for the file sender")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		return &FileSender{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail sender: %s", kind)
+	}
+}
+
+// WriterSender writes messages to W, for local development
+type WriterSender struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+func (s *WriterSender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := io.WriteString(s.W, format(msg)+"\n")
+	return err
+}
+
+// FileSender writes every message to a new .eml file in Dir, for local development
+// and inspecting mails in tests
+type FileSender struct {
+	Dir string
+}
+
+func (s *FileSender) Send(msg Message) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+
+	return os.WriteFile(filepath.Join(s.Dir, name), []byte(format(msg)), 0o644)
+}
+
+func format(msg Message) string {
+	return fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", msg.To, msg.Subject, msg.Body)
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,221 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwks is a JSON Web Key Set document
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// RemoteKeySet is a JWKS served at a URL, such as a provider's jwks_uri. It is fetched
+// on first use and fetched again when a token names a kid it doesn't know, at most
+// once per jwksRefreshInterval, since keys get rotated.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      *keySet
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet returns the key set at url, fetched with client, or with
+// http.DefaultClient if client is nil
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &RemoteKeySet{url: url, client: client}
+}
+
+// Key returns the key with the given kid, checking it fits the token's algorithm alg
+func (r *RemoteKeySet) Key(ctx context.Context, kid, alg string) (interface{}, error) {
+	r.mu.Lock()
+	keys := r.keys
+	fetchedAt := r.fetchedAt
+	r.mu.Unlock()
+
+	if keys != nil {
+		key, err := keys.find(kid, alg)
+		if err == nil || time.Since(fetchedAt) < jwksRefreshInterval {
+			return key, err
+		}
+	}
+
+	var fetched jwks
+	if err := getJSON(ctx, r.client, r.url, &fetched); err != nil {
+		return nil, fmt.Errorf("fetching jwks: %w", err)
+	}
+
+	keys, err := fetched.keySet()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.fetchedAt = time.Now()
+	r.mu.Unlock()
+
+	return keys.find(kid, alg)
+}
+
+// keySet holds the provider's signing keys by kid
+type keySet struct {
+	keys map[string]parsedKey
+}
+
+type parsedKey struct {
+	alg    string
+	public interface{}
+}
+
+// keySet parses the keys usable for signatures. Keys of unknown types are skipped.
+func (s jwks) keySet() (*keySet, error) {
+	set := &keySet{keys: map[string]parsedKey{}}
+
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		public, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+
+		set.keys[k.Kid] = parsedKey{alg: k.Alg, public: public}
+	}
+
+	if len(set.keys) == 0 {
+		return nil, errors.New("jwks has no usable keys")
+	}
+
+	return set, nil
+}
+
+// find returns the key with the given kid, checking it fits the token's algorithm.
+// A token without kid is accepted if the provider has a single key.
+func (s *keySet) find(kid, alg string) (interface{}, error) {
+	key, ok := s.keys[kid]
+	if !ok && kid == "" && len(s.keys) == 1 {
+		for _, only := range s.keys {
+			key, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown kid: %q", kid)
+	}
+
+	if key.alg != "" && key.alg != alg {
+		return nil, fmt.Errorf("key %q is not for %s", kid, alg)
+	}
+
+	var fits bool
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		fits = strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		fits = strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		fits = alg == "EdDSA"
+	}
+	if !fits {
+		return nil, fmt.Errorf("key %q is not for %s", kid, alg)
+	}
+
+	return key.public, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,413 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testClientID     = "booking-system"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:3000/callback"
+	testNonce        = "nonce-1"
+)
+
+// testProvider is an OpenID provider serving discovery, its JWKS and a token endpoint
+// that answers the code "good-code" with idToken
+type testProvider struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PrivateKey
+	idToken     string
+	jwksFetches int
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+
+	tp := &testProvider{keys: map[string]*rsa.PrivateKey{"key-1": newRSAKey(t)}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 tp.URL,
+			"authorization_endpoint": tp.URL + "/authorize",
+			"token_endpoint":         tp.URL + "/token",
+			"jwks_uri":               tp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+
+		tp.jwksFetches++
+
+		var set jwks
+		for kid, key := range tp.keys {
+			set.Keys = append(set.Keys, jwk{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		writeJSON(w, set)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || id != testClientID || secret != testClientSecret {
+			http.Error(w, "invalid client", http.StatusUnauthorized)
+			return
+		}
+		if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != "good-code" ||
+			r.PostFormValue("code_verifier") != "verifier" || r.PostFormValue("redirect_uri") != testRedirectURL {
+			http.Error(w, "invalid grant", http.StatusBadRequest)
+			return
+		}
+
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		writeJSON(w, map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": tp.idToken})
+	})
+
+	tp.Server = httptest.NewServer(mux)
+	t.Cleanup(tp.Close)
+
+	return tp
+}
+
+// rotate replaces the provider's keys with a single new one under kid
+func (tp *testProvider) rotate(t *testing.T, kid string) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	tp.keys = map[string]*rsa.PrivateKey{kid: newRSAKey(t)}
+}
+
+// fetches is how many times the JWKS was fetched
+func (tp *testProvider) fetches() int {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	return tp.jwksFetches
+}
+
+// claims returns valid ID token claims, to be changed by each test
+func (tp *testProvider) claims() jwt.MapClaims {
+	now := time.Now()
+
+	return jwt.MapClaims{
+		"iss":            tp.URL,
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "user@example.com",
+		"email_verified": "true",
+		"name":           "Test User",
+	}
+}
+
+// sign signs claims with the provider's key kid
+func (tp *testProvider) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	tp.mu.Lock()
+	key := tp.keys[kid]
+	tp.mu.Unlock()
+
+	return signWith(t, key, kid, claims)
+}
+
+func (tp *testProvider) provider() *Provider {
+	return NewProvider(Config{
+		Name:         "test",
+		Issuer:       tp.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, tp.Client())
+}
+
+func signWith(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing id token: %v", err)
+	}
+
+	return signed
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	return key
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	tp := newTestProvider(t)
+
+	authURL, err := tp.provider().AuthCodeURL(context.Background(), "state-1", testNonce, "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", authURL, err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != tp.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %q, want %q", got, tp.URL+"/authorize")
+	}
+
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge_method": "S256",
+		// base64url(sha256("verifier"))
+		"code_challenge": "iMnq5o6zALKXGivsnlom_0F5_WYda32GHkxlV7mq7hQ",
+	}
+	for param, value := range want {
+		if got := q.Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	tp := newTestProvider(t)
+
+	p := tp.provider()
+	p.Issuer = tp.URL + "/other"
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", testNonce, "verifier"); err == nil {
+		t.Fatal("AuthCodeURL succeeded with a discovery document for another issuer")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tp := newTestProvider(t)
+	tp.idToken = tp.sign(t, "key-1", tp.claims())
+
+	p := tp.provider()
+
+	claims, err := p.Exchange(context.Background(), "good-code", "verifier", testNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	want := Claims{Subject: "subject-1", Email: "user@example.com", EmailVerified: true, Name: "Test User"}
+	if *claims != want {
+		t.Errorf("claims = %+v, want %+v", *claims, want)
+	}
+
+	if _, err = p.Exchange(context.Background(), "bad-code", "verifier", testNonce); err == nil {
+		t.Error("Exchange succeeded with a code the provider refused")
+	}
+	if _, err = p.Exchange(context.Background(), "good-code", "other-verifier", testNonce); err == nil {
+		t.Error("Exchange succeeded with the wrong code verifier")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	tp := newTestProvider(t)
+	otherKey := newRSAKey(t)
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: func() string { return tp.sign(t, "key-1", tp.claims()) },
+		},
+		{
+			name: "several audiences with this client as authorized party",
+			token: func() string {
+				c := tp.claims()
+				c["aud"] = []string{testClientID, "other-client"}
+				c["azp"] = testClientID
+				return tp.sign(t, "key-1", c)
+			},
+		},
+		{
+			name:    "bad signature",
+			token:   func() string { return signWith(t, otherKey, "key-1", tp.claims()) },
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				c := tp.claims()
+				c["iss"] = "https://attacker.example.com"
+				return tp.sign(t, "key-1", c)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				c := tp.claims()
+				c["aud"] = "other-client"
+				return tp.sign(t, "key-1", c)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "wrong authorized party",
+			token: func() string {
+				c := tp.claims()
+				c["aud"] = []string{testClientID, "other-client"}
+				c["azp"] = "other-client"
+				return tp.sign(t, "key-1", c)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				c := tp.claims()
+				c["exp"] = time.Now().Add(-clockSkew - time.Minute).Unix()
+				return tp.sign(t, "key-1", c)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "no expiry",
+			token: func() string {
+				c := tp.claims()
+				delete(c, "exp")
+				return tp.sign(t, "key-1", c)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "issued in the future",
+			token: func() string {
+				c := tp.claims()
+				c["iat"] = time.Now().Add(clockSkew + time.Minute).Unix()
+				return tp.sign(t, "key-1", c)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "nonce mismatch",
+			token: func() string {
+				c := tp.claims()
+				c["nonce"] = "other-nonce"
+				return tp.sign(t, "key-1", c)
+			},
+			wantErr: ErrNonceMismatch,
+		},
+		{
+			name:    "unknown kid",
+			token:   func() string { return signWith(t, otherKey, "key-2", tp.claims()) },
+			wantErr: ErrInvalidIDToken,
+		},
+	}
+
+	p := tp.provider()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.VerifyIDToken(context.Background(), tt.token(), testNonce)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyIDToken error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	tp := newTestProvider(t)
+	p := tp.provider()
+
+	if _, err := p.VerifyIDToken(context.Background(), tp.sign(t, "key-1", tp.claims()), testNonce); err != nil {
+		t.Fatalf("VerifyIDToken before rotation: %v", err)
+	}
+
+	tp.rotate(t, "key-2")
+	rotated := tp.sign(t, "key-2", tp.claims())
+
+	// the JWKS was just fetched, so an unknown kid doesn't fetch it again yet
+	if _, err := p.VerifyIDToken(context.Background(), rotated, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("VerifyIDToken right after rotation error = %v, want %v", err, ErrInvalidIDToken)
+	}
+	if n := tp.fetches(); n != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", n)
+	}
+
+	keys := p.discovery.keys
+	keys.mu.Lock()
+	keys.fetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	keys.mu.Unlock()
+
+	if _, err := p.VerifyIDToken(context.Background(), rotated, testNonce); err != nil {
+		t.Fatalf("VerifyIDToken after rotation: %v", err)
+	}
+	if n := tp.fetches(); n != 2 {
+		t.Fatalf("jwks fetched %d times, want 2", n)
+	}
+
+	// the old key is gone with the rotation
+	if _, err := p.VerifyIDToken(context.Background(), signWith(t, newRSAKey(t), "key-1", tp.claims()), testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("VerifyIDToken with a retired kid error = %v, want %v", err, ErrInvalidIDToken)
+	}
+}
+
+func TestAudienceUnmarshal(t *testing.T) {
+	for raw, want := range map[string][]string{
+		`"client"`:           {"client"},
+		`["client","other"]`: {"client", "other"},
+	} {
+		var a audience
+		if err := json.Unmarshal([]byte(raw), &a); err != nil {
+			t.Fatalf("unmarshal %s: %v", raw, err)
+		}
+		if strings.Join(a, ",") != strings.Join(want, ",") {
+			t.Errorf("unmarshal %s = %v, want %v", raw, a, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	loggerpb "proto/logger"
+	reservationpb "proto/reservation"
+)
+
+var errExportFailed = errors.New("error exporting data")
+
+// LogEntry is one entry of the logger service
+type LogEntry struct {
+	ID        string    `json:"id,omitempty"`
+	Name      string    `json:"name"`
+	Data      string    `json:"data"`
+	UserID    string    `json:"userId,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DataExport is the bundle export_data returns: everything the services hold about
+// the user
+type DataExport struct {
+	ExportedAt   time.Time         `json:"exportedAt"`
+	Profile      *Profile          `json:"profile"`
+	Reservations []ReservationData `json:"reservations"`
+	AuthLog      []LogEntry        `json:"authLog"`
+}
+
+// applyAccountDeletion has the reservation service apply its deletion policy to the
+// caller's reservations before auth-svc deletes the account. Once the account is gone
+// a failure could no longer be retried, leaving upcoming reservations and remarks
+// behind, so the deletion is refused instead. If auth-svc then refuses the deletion,
+// the user can try again; the reservation service's side is safe to repeat.
+func (app *Config) applyAccountDeletion(w http.ResponseWriter, r *http.Request, caller Caller) bool {
+	ctx := rpcContext(r)
+
+	_, err := reservationClient.ApplyAccountDeletion(ctx, &reservationpb.AccountRequest{Caller: caller.proto()})
+	if err != nil {
+		log.Printf("Error handling reservations for account deletion of id: %v: %v\n", caller.UserID, err)
+		app.reservationErrorJSON(w, err, "error deleting account")
+		return false
+	}
+
+	return true
+}
+
+// exportData completes the profile returned by auth-svc with the user's reservations
+// and auth log entries
+func (app *Config) exportData(ctx context.Context, caller Caller, profile *Profile) (*DataExport, error) {
+	export := DataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    profile,
+	}
+
+	reservations, err := reservationClient.ExportReservations(ctx, &reservationpb.AccountRequest{Caller: caller.proto()})
+	if err != nil {
+		return nil, fmt.Errorf("exporting reservations: %w", err)
+	}
+	export.Reservations = reservationsFromProto(reservations.GetReservations())
+
+	entries, err := loggerClient.EntriesForUser(ctx, &loggerpb.EntriesRequest{UserId: caller.UserID, NamePrefix: "Auth_"})
+	if err != nil {
+		return nil, fmt.Errorf("exporting log entries: %w", err)
+	}
+
+	export.AuthLog = make([]LogEntry, 0, len(entries.GetEntries()))
+	for _, e := range entries.GetEntries() {
+		export.AuthLog = append(export.AuthLog, logEntryFromProto(e))
+	}
+
+	return &export, nil
+}
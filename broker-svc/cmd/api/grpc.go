@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	loggerpb "proto/logger"
+	reservationpb "proto/reservation"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The reservation and logger services are called through pools of connections that
+// reconnect on their own, guarded by a Dependency each
+var (
+	reservationClient reservationpb.ReservationServiceClient
+	loggerClient      loggerpb.LoggerServiceClient
+)
+
+// The calls that only read are safe to retry when the service is unreachable
+var (
+	idempotentReservationRPCs = map[string]bool{
+		reservationpb.ReservationService_GetReservation_FullMethodName:     true,
+		reservationpb.ReservationService_ListReservations_FullMethodName:   true,
+		reservationpb.ReservationService_Availability_FullMethodName:       true,
+		reservationpb.ReservationService_ListRestaurants_FullMethodName:    true,
+		reservationpb.ReservationService_ExportReservations_FullMethodName: true,
+	}
+	idempotentLoggerRPCs = map[string]bool{
+		loggerpb.LoggerService_EntriesForUser_FullMethodName: true,
+	}
+)
+
+// rpcContext is the context of the calls made for a request. It carries the request's
+// trace and ID, but the calls finish even if the client goes away.
+func rpcContext(r *http.Request) context.Context {
+	return context.WithoutCancel(r.Context())
+}
+
+func (c Caller) proto() *reservationpb.Caller {
+	return &reservationpb.Caller{
+		UserId: c.UserID,
+		Role:   c.Role,
+	}
+}
+
+func reservationToProto(rd ReservationData) *reservationpb.Reservation {
+	return &reservationpb.Reservation{
+		Id:              rd.ReservationID,
+		RestaurantId:    rd.RestaurantID,
+		UserId:          rd.UserId,
+		Count:           int32(rd.Count),
+		ReservationTime: timeToProto(rd.ReservationTime),
+		Remarks:         rd.Remarks,
+	}
+}
+
+func reservationFromProto(r *reservationpb.Reservation) ReservationData {
+	return ReservationData{
+		ReservationID:   r.GetId(),
+		RestaurantID:    r.GetRestaurantId(),
+		UserId:          r.GetUserId(),
+		Count:           int(r.GetCount()),
+		ReservationTime: timeFromProto(r.GetReservationTime()),
+		Remarks:         r.GetRemarks(),
+		Status:          r.GetStatus(),
+		CreatedAt:       timeFromProto(r.GetCreatedAt()),
+	}
+}
+
+func reservationsFromProto(reservations []*reservationpb.Reservation) []ReservationData {
+	out := make([]ReservationData, 0, len(reservations))
+	for _, r := range reservations {
+		out = append(out, reservationFromProto(r))
+	}
+	return out
+}
+
+func restaurantToProto(rd RestaurantData) *reservationpb.Restaurant {
+	r := &reservationpb.Restaurant{
+		Id:       rd.RestaurantID,
+		Name:     rd.Name,
+		Address:  rd.Address,
+		Timezone: rd.Timezone,
+		Capacity: int32(rd.Capacity),
+	}
+	for _, t := range rd.Tables {
+		r.Tables = append(r.Tables, &reservationpb.Table{Id: t.TableID, Label: t.Label, Seats: int32(t.Seats)})
+	}
+	for _, h := range rd.OpeningHours {
+		r.OpeningHours = append(r.OpeningHours, &reservationpb.OpeningHours{Weekday: int32(h.Weekday), Opens: h.Opens, Closes: h.Closes})
+	}
+	for _, b := range rd.BlackoutDates {
+		r.BlackoutDates = append(r.BlackoutDates, &reservationpb.BlackoutDate{Date: b.Date, Reason: b.Reason})
+	}
+
+	return r
+}
+
+func restaurantFromProto(r *reservationpb.Restaurant) RestaurantData {
+	rd := RestaurantData{
+		RestaurantID:  r.GetId(),
+		Name:          r.GetName(),
+		Address:       r.GetAddress(),
+		Timezone:      r.GetTimezone(),
+		Capacity:      int(r.GetCapacity()),
+		Tables:        []TableData{},
+		OpeningHours:  []OpeningHours{},
+		BlackoutDates: []BlackoutDate{},
+		CreatedAt:     timeFromProto(r.GetCreatedAt()),
+	}
+	for _, t := range r.GetTables() {
+		rd.Tables = append(rd.Tables, TableData{TableID: t.GetId(), Label: t.GetLabel(), Seats: int(t.GetSeats())})
+	}
+	for _, h := range r.GetOpeningHours() {
+		rd.OpeningHours = append(rd.OpeningHours, OpeningHours{Weekday: int(h.GetWeekday()), Opens: h.GetOpens(), Closes: h.GetCloses()})
+	}
+	for _, b := range r.GetBlackoutDates() {
+		rd.BlackoutDates = append(rd.BlackoutDates, BlackoutDate{Date: b.GetDate(), Reason: b.GetReason()})
+	}
+
+	return rd
+}
+
+func logEntryFromProto(e *loggerpb.LogEntry) LogEntry {
+	return LogEntry{
+		ID:        e.GetId(),
+		Name:      e.GetName(),
+		Data:      e.GetData(),
+		UserID:    e.GetUserId(),
+		RequestID: e.GetRequestId(),
+		CreatedAt: timeFromProto(e.GetCreatedAt()),
+	}
+}
+
+// timeToProto leaves zero times unset, so they read back as zero
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
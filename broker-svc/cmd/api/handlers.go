@@ -2,25 +2,43 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"net/rpc"
-	"os"
 	"strings"
 	"time"
 
+	"proto/requestid"
+	reservationpb "proto/reservation"
+
 	"github.com/golang-jwt/jwt"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-var mySigningKey = []byte(os.Getenv("TOKEN_SECRET"))
+// accessTokenTTL is how long an access token is valid. Clients renew it with the
+// refresh token returned next to it.
+const accessTokenTTL = time.Hour
+
+// Reservations created with an Idempotency-Key header are booked once per key; the
+// reservation service remembers keys up to maxIdempotencyKeyLength long
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
 
 type RequestPayload struct {
 	Action      string             `json:"action"`
 	Auth        AuthRequest        `json:"auth,omitempty"`
 	Reservation ReservationRequest `json:"reservation,omitempty"`
+	Restaurant  RestaurantRequest  `json:"restaurant,omitempty"`
 }
 
 type AuthRequest struct {
@@ -29,28 +47,106 @@ type AuthRequest struct {
 }
 
 type AuthPayload struct {
-	Email    string `json:"email"`
-	FullName string `json:"fullName,omitempty"`
-	Password string `json:"password"`
+	Email        string `json:"email"`
+	FullName     string `json:"fullName,omitempty"`
+	Password     string `json:"password"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	Token        string `json:"token,omitempty"`
+
+	Code           string `json:"code,omitempty"`
+	ChallengeToken string `json:"challengeToken,omitempty"`
+	ClientIP       string `json:"clientIp,omitempty"`
+	Role           string `json:"role,omitempty"`
+	NewPassword    string `json:"newPassword,omitempty"`
+
+	// external provider login
+	Provider          string `json:"provider,omitempty"`
+	State             string `json:"state,omitempty"`
+	AuthorizationCode string `json:"authorizationCode,omitempty"`
+}
+
+// AuthorizationRedirect is returned by oidc_start; the client sends the user there and
+// passes the state and code it gets back to oidc_callback
+type AuthorizationRedirect struct {
+	AuthorizationURL string `json:"authorizationURL"`
+}
+
+// TwoFactorChallenge is returned by login when the account has two-factor enabled
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+}
+
+// TwoFactorEnrollment is returned by 2fa_enroll (secret and URI) and 2fa_confirm
+// (recovery codes)
+type TwoFactorEnrollment struct {
+	Secret        string   `json:"secret,omitempty"`
+	OtpauthURI    string   `json:"otpauthURI,omitempty"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+// Profile is returned by get_profile
+type Profile struct {
+	ID               string    `json:"id"`
+	Email            string    `json:"email"`
+	FullName         string    `json:"fullName"`
+	Role             string    `json:"role"`
+	EmailVerified    bool      `json:"emailVerified"`
+	TwoFactorEnabled bool      `json:"twoFactorEnabled"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// TokenPair is returned on login, refresh and password change
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"`
 }
 
 type ReservationRequest struct {
 	Action          string          `json:"action"`
 	ReservationData ReservationData `json:"reservationData"`
+	Date            string          `json:"date,omitempty"`
+	Page            int             `json:"page,omitempty"`
+	PageSize        int             `json:"pageSize,omitempty"`
 }
 
 type ReservationData struct {
 	ReservationID   string    `json:"id,omitempty"`
 	RestaurantID    string    `json:"restaurantID,omitempty"`
 	UserId          string    `json:"userID,omitempty"`
-	Count           string    `json:"count,omitempty"`
-	ReservationTime string    `json:"reservationTime,omitempty"`
+	Count           int       `json:"count,omitempty"`
+	ReservationTime time.Time `json:"reservationTime,omitempty"`
 	Remarks         string    `json:"remarks,omitempty"`
+	Status          string    `json:"status,omitempty"`
 	CreatedAt       time.Time `json:"createdAt,omitempty"`
 }
 
-type RPCPayload struct {
-	ReservationData ReservationData
+// ReservationList is the reply of the reservation service's ListReservations
+type ReservationList struct {
+	Reservations []ReservationData `json:"reservations"`
+	Page         int               `json:"page"`
+	PageSize     int               `json:"pageSize"`
+	Total        int               `json:"total"`
+}
+
+// Availability is the reply of the reservation service's Availability
+type Availability struct {
+	RestaurantID string   `json:"restaurantID"`
+	Date         string   `json:"date"`
+	PartySize    int      `json:"partySize"`
+	Slots        []string `json:"slots"`
+}
+
+// reservationRPCStatuses are the HTTP statuses of the gRPC codes the reservation
+// service returns for errors the user may see. Their message is passed on as is.
+var reservationRPCStatuses = map[codes.Code]int{
+	codes.NotFound:           http.StatusNotFound,
+	codes.FailedPrecondition: http.StatusConflict,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.InvalidArgument:    http.StatusUnprocessableEntity,
+	codes.PermissionDenied:   http.StatusForbidden,
 }
 
 func (app *Config) Broker(w http.ResponseWriter, r *http.Request) {
@@ -67,47 +163,176 @@ func (app *Config) HandleSubmission(w http.ResponseWriter, r *http.Request) {
 
 	err := app.readJSON(w, r, &requestPayload)
 	if err != nil {
+		if errs := decodeFieldErrors(err); len(errs) > 0 {
+			app.validationErrorJSON(w, "invalid request", errs)
+			return
+		}
 		app.errorJSON(w, err)
 		return
 	}
 
 	switch requestPayload.Action {
 	case "auth":
-		app.authenticate(w, requestPayload.Auth)
+		app.authenticate(w, r, requestPayload.Auth)
 	case "reserve":
 		app.reservation(w, r, requestPayload.Reservation)
+	case "restaurant":
+		app.restaurant(w, r, requestPayload.Restaurant)
 	default:
 		app.errorJSON(w, errors.New("unknown action"))
 	}
 }
 
-func (app *Config) authenticate(w http.ResponseWriter, a AuthRequest) {
-	jsonData, _ := json.MarshalIndent(a, "", "\t")
+// authActionsWithUser are the auth actions that act on the logged in user. The broker
+// checks the access token and passes it on to auth-svc, which verifies it again and
+// takes the user from it; for every other action no token is passed on.
+var authActionsWithUser = map[string]bool{
+	"2fa_enroll":  true,
+	"2fa_confirm": true,
+	"2fa_disable": true,
+	"set_role":    true,
+
+	"get_profile":     true,
+	"update_profile":  true,
+	"change_email":    true,
+	"change_password": true,
+
+	"delete_account": true,
+	"export_data":    true,
+}
 
-	request, err := http.NewRequest("POST", "http://auth-svc:8181/auth", bytes.NewBuffer(jsonData))
+// authActionsIdempotent are the auth actions that only read, so they are retried when
+// auth-svc can't be reached. export_data is left out as it writes an audit entry.
+var authActionsIdempotent = map[string]bool{
+	"get_profile": true,
+}
 
-	if err != nil {
-		log.Printf("Error creating auth service request: %v\n", err)
-		app.errorJSON(w, fmt.Errorf("error calling authentication service"))
+// authClient calls auth-svc, passing the trace context on in the traceparent header.
+// The deadline of each call comes from app.Auth. It is set up in main, as auth-svc
+// only accepts the broker's certificate (see newAuthClient).
+var authClient *http.Client
+
+// newAuthClient returns a client that calls auth-svc over mutual TLS with tlsConfig
+func newAuthClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: otelhttp.NewTransport(transport)}
+}
+
+// authActionsWithValidation are the actions without a logged in user whose validation
+// errors, such as a password refused by the password policy, are passed on as well
+var authActionsWithValidation = map[string]bool{
+	"signup":         true,
+	"reset_password": true,
+	"oidc_start":     true,
+}
+
+// authActionPermissions are the auth actions that need more than a logged in user.
+// auth-svc checks the stored role again, since the token's role may be stale.
+var authActionPermissions = map[string]permission{
+	"set_role": permManageRoles,
+}
+
+func (app *Config) authenticate(w http.ResponseWriter, r *http.Request, a AuthRequest) {
+	a.AuthData.ClientIP = clientIP(r)
+
+	var caller Caller
+	if authActionsWithUser[a.Action] {
+		var ok bool
+		caller, ok = app.authenticatedUser(w, r)
+		if !ok {
+			return
+		}
+		if p, ok := authActionPermissions[a.Action]; ok && !app.authorize(w, caller, p) {
+			return
+		}
+	}
+
+	if !app.limitAction(w, r, "auth/"+a.Action, clientIP(r)) {
+		return
+	}
+
+	if a.Action == "delete_account" && !app.applyAccountDeletion(w, r, caller) {
 		return
 	}
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+	jsonData, _ := json.MarshalIndent(a, "", "\t")
+
+	response, err := app.Auth.callHTTP(r.Context(), authClient, authActionsIdempotent[a.Action], func(ctx context.Context) (*http.Request, error) {
+		request, err := http.NewRequestWithContext(ctx, "POST", "https://auth-svc:8181/auth", bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set(requestid.Header, requestid.FromContext(ctx))
+		if authActionsWithUser[a.Action] {
+			request.Header.Set("Authorization", r.Header.Get("Authorization"))
+		}
+
+		return request, nil
+	})
 	if err != nil {
+		if app.unavailableJSON(w, err) {
+			return
+		}
 		log.Printf("Error calling auth service: %v\n", err)
-		app.errorJSON(w, fmt.Errorf("error calling authentication service"))
+		app.errorJSON(w, fmt.Errorf("error calling authentication service"), upstreamStatus(err))
 		return
 	}
 
 	defer response.Body.Close()
 
 	if response.StatusCode == http.StatusUnauthorized {
-		app.errorJSON(w, errors.New("invalid credentials"))
+		switch a.Action {
+		case "login":
+			app.errorJSON(w, errors.New("invalid credentials"), unauthorizedStatus(r))
+		case "refresh", "logout":
+			app.errorJSON(w, errors.New("invalid refresh token"), http.StatusUnauthorized)
+		default:
+			var refusal authResponse
+			if err := json.NewDecoder(response.Body).Decode(&refusal); err != nil || refusal.Message == "" {
+				refusal.Message = "unauthorized"
+			}
+			app.errorJSON(w, errors.New(refusal.Message), http.StatusUnauthorized)
+		}
 		return
-	} else if response.StatusCode != http.StatusOK {
+	} else if response.StatusCode == http.StatusForbidden && a.Action == "set_role" {
+		app.errorJSON(w, errForbidden, http.StatusForbidden)
+		return
+	} else if response.StatusCode == http.StatusNotFound && a.Action == "set_role" {
+		app.errorJSON(w, errors.New("user not found"), http.StatusNotFound)
+		return
+	} else if (authActionsWithUser[a.Action] || authActionsWithValidation[a.Action]) &&
+		(response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusConflict) {
+		// validation errors on the user's own account are meant for the user
+		var refusal authResponse
+		if err := json.NewDecoder(response.Body).Decode(&refusal); err != nil || refusal.Message == "" {
+			app.errorJSON(w, errors.New("error calling auth service"), http.StatusBadGateway)
+			return
+		}
+		app.errorJSON(w, errors.New(refusal.Message), response.StatusCode)
+		return
+	} else if response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusLocked ||
+		response.StatusCode == http.StatusTooManyRequests {
+		// coded refusals, such as an unverified email or a locked account, are passed
+		// on as they are
+		var refusal authResponse
+		if err := json.NewDecoder(response.Body).Decode(&refusal); err != nil || refusal.Code == "" {
+			app.errorJSON(w, errors.New("error calling auth service"), http.StatusBadGateway)
+			return
+		}
+		if retryAfter := response.Header.Get("Retry-After"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		app.errorCodeJSON(w, errors.New(refusal.Message), refusal.Code, response.StatusCode)
+		return
+	} else if response.StatusCode >= http.StatusBadRequest && response.StatusCode < http.StatusInternalServerError {
+		// any other refusal is about the request, but its reason isn't passed on
 		app.errorJSON(w, errors.New("error calling auth service"))
 		return
+	} else if response.StatusCode != http.StatusOK {
+		app.errorJSON(w, errors.New("error calling auth service"), http.StatusBadGateway)
+		return
 	}
 
 	var auhResponse authResponse
@@ -115,7 +340,7 @@ func (app *Config) authenticate(w http.ResponseWriter, a AuthRequest) {
 	err = json.NewDecoder(response.Body).Decode(&auhResponse)
 	if err != nil {
 		log.Printf("Error reading auth service response: %v\n", err)
-		app.errorJSON(w, err)
+		app.errorJSON(w, errors.New("error calling auth service"), http.StatusBadGateway)
 		return
 	}
 
@@ -128,74 +353,327 @@ func (app *Config) authenticate(w http.ResponseWriter, a AuthRequest) {
 	payload.Error = false
 	payload.Message = fmt.Sprintf("Authentication Service!: %s", auhResponse.Message)
 
-	if a.Action == "signup" {
+	switch {
+	case (a.Action == "login" || a.Action == "oidc_callback") && auhResponse.Data.TwoFactorRequired:
+		// the client completes the login with 2fa_verify
+		payload.Data = TwoFactorChallenge{
+			TwoFactorRequired: true,
+			ChallengeToken:    auhResponse.Data.ChallengeToken,
+		}
+		// the REST route answers 202 too, so the challenge can't pass for a login
+		keepStatus(w)
+		app.writeJSON(w, http.StatusAccepted, payload)
+		return
+	case a.Action == "oidc_start":
+		payload.Data = AuthorizationRedirect{AuthorizationURL: auhResponse.Data.AuthorizationURL}
+		app.writeJSON(w, http.StatusAccepted, payload)
+		return
+	case a.Action == "2fa_enroll":
+		payload.Data = TwoFactorEnrollment{
+			Secret:     auhResponse.Data.Secret,
+			OtpauthURI: auhResponse.Data.OtpauthURI,
+		}
+		app.writeJSON(w, http.StatusAccepted, payload)
+		return
+	case a.Action == "2fa_confirm":
+		payload.Data = TwoFactorEnrollment{
+			RecoveryCodes: auhResponse.Data.RecoveryCodes,
+		}
+		app.writeJSON(w, http.StatusAccepted, payload)
+		return
+	case a.Action == "get_profile":
+		payload.Data = auhResponse.Data.Profile
+		app.writeJSON(w, http.StatusAccepted, payload)
+		return
+	case a.Action == "export_data":
+		export, err := app.exportData(rpcContext(r), caller, auhResponse.Data.Profile)
+		if err != nil {
+			log.Printf("Error exporting data for id: %v: %v\n", caller.UserID, err)
+			if app.unavailableJSON(w, err) {
+				return
+			}
+			app.errorJSON(w, errExportFailed, upstreamStatus(err))
+			return
+		}
+		payload.Data = export
+		app.writeJSON(w, http.StatusAccepted, payload)
+		return
+	case a.Action != "login" && a.Action != "oidc_callback" && a.Action != "refresh" &&
+		a.Action != "2fa_verify" && a.Action != "change_password":
+		// only the login steps, refresh and a password change hand out tokens
 		app.writeJSON(w, http.StatusAccepted, payload)
 		return
 	}
 
-	token, err := generateToken(auhResponse.Data.ID)
+	token, err := app.generateToken(auhResponse.Data.ID, auhResponse.Data.Role)
 	if err != nil {
 		log.Printf("Error generating token: %v\n", err)
-		app.errorJSON(w, err)
+		app.errorJSON(w, errors.New("error generating token"), http.StatusInternalServerError)
 		return
 	}
 
-	payload.Data = token
+	payload.Data = TokenPair{
+		AccessToken:  token,
+		RefreshToken: auhResponse.Data.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}
 
 	app.writeJSON(w, http.StatusAccepted, payload)
 }
 
 func (app *Config) reservation(w http.ResponseWriter, r *http.Request, reservationReq ReservationRequest) {
-	tokenString, err := extractToken(r)
-	if err != nil {
-		log.Printf("Error extracting token: %v\n", err)
-		app.errorJSON(w, fmt.Errorf("invalid token"))
+	caller, ok := app.authenticatedUser(w, r)
+	if !ok {
 		return
 	}
 
-	id, err := verifyJWT(tokenString)
-	if err != nil {
-		log.Printf("Error verifying JWT: %v\n", err)
-		app.errorJSON(w, fmt.Errorf("unauthorized"))
+	reservationReq.ReservationData.UserId = caller.UserID
+
+	// listing a restaurant's reservations, rather than one's own, is for staff
+	needed := permOwnReservations
+	if reservationReq.Action == "list" && reservationReq.ReservationData.RestaurantID != "" {
+		needed = permRestaurantReservations
+	}
+	if !app.authorize(w, caller, needed) {
 		return
 	}
 
-	reservationReq.ReservationData.UserId = id
+	if errs := validateReservationRequest(reservationReq, time.Now()); len(errs) > 0 {
+		app.validationErrorJSON(w, "invalid reservation", errs)
+		return
+	}
+
+	if !app.limitAction(w, r, "reserve/"+reservationReq.Action, caller.UserID) {
+		return
+	}
 
 	switch reservationReq.Action {
 	case "add":
-		app.createReservation(w, reservationReq.ReservationData)
+		app.createReservation(w, r, caller, reservationReq.ReservationData, r.Header.Get(idempotencyKeyHeader))
+	case "get":
+		app.getReservation(w, r, caller, reservationReq.ReservationData)
+	case "list":
+		app.listReservations(w, r, caller, reservationReq)
+	case "update":
+		app.updateReservation(w, r, caller, reservationReq.ReservationData)
+	case "cancel":
+		app.cancelReservation(w, r, caller, reservationReq.ReservationData)
+	case "availability":
+		app.availability(w, r, reservationReq)
 	default:
 		app.errorJSON(w, errors.New("unknown action"))
 	}
 }
 
-func (app *Config) createReservation(w http.ResponseWriter, rd ReservationData) {
-	client, err := rpc.Dial("tcp", "reservation-svc:5002")
+// createReservation books a reservation. A request repeated with the same idempotency
+// key gets the first one's result, marked with the Idempotent-Replayed header.
+func (app *Config) createReservation(w http.ResponseWriter, r *http.Request, caller Caller, rd ReservationData, idempotencyKey string) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.CreateReservation(ctx, &reservationpb.ReservationRequest{
+		Caller: caller.proto(),
+		Reservation: reservationToProto(ReservationData{
+			UserId:          rd.UserId,
+			RestaurantID:    rd.RestaurantID,
+			Count:           rd.Count,
+			ReservationTime: rd.ReservationTime,
+			Remarks:         rd.Remarks,
+		}),
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		log.Println("Error sending payload to reservation rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error creating reservation booking")
+		return
+	}
+
+	if result.GetReplayed() {
+		w.Header().Set(idempotentReplayedHeader, "true")
+		// nothing was created, so the REST route answers 200 rather than 201
+		keepStatus(w)
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = fmt.Sprintf("Reservation Service!: %s", result.GetMessage())
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) getReservation(w http.ResponseWriter, r *http.Request, caller Caller, rd ReservationData) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.GetReservation(ctx, &reservationpb.ReservationRequest{
+		Caller: caller.proto(),
+		Reservation: reservationToProto(ReservationData{
+			ReservationID: rd.ReservationID,
+			UserId:        rd.UserId,
+		}),
+	})
 	if err != nil {
-		log.Println("Error connecting to reservation rpc from broker: ", err)
+		log.Println("Error getting reservation via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error getting reservation")
+		return
 	}
 
-	var rpcPayload RPCPayload
-	rpcPayload.ReservationData.UserId = rd.UserId
-	rpcPayload.ReservationData.RestaurantID = rd.RestaurantID
-	rpcPayload.ReservationData.Count = rd.Count
-	rpcPayload.ReservationData.ReservationTime = rd.ReservationTime
-	rpcPayload.ReservationData.Remarks = rd.Remarks
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = "Reservation Service!: Reservation fetched successfully"
+	payload.Data = reservationFromProto(result)
+	app.writeJSON(w, http.StatusOK, payload)
+}
 
-	var result string
-	err = client.Call("RPCServer.CreateReservation", rpcPayload, &result)
+func (app *Config) listReservations(w http.ResponseWriter, r *http.Request, caller Caller, reservationReq ReservationRequest) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.ListReservations(ctx, &reservationpb.ReservationRequest{
+		Caller: caller.proto(),
+		Reservation: reservationToProto(ReservationData{
+			UserId:       reservationReq.ReservationData.UserId,
+			RestaurantID: reservationReq.ReservationData.RestaurantID,
+		}),
+		Page:     int32(reservationReq.Page),
+		PageSize: int32(reservationReq.PageSize),
+	})
 	if err != nil {
-		log.Println("Error sending payload to reservation rpc from broker: ", err)
-		app.errorJSON(w, fmt.Errorf("error creating reservation booking"))
+		log.Println("Error listing reservations via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error listing reservations")
+		return
 	}
 
 	var payload jsonResponse
 	payload.Error = false
-	payload.Message = fmt.Sprintf("Reservation Service!: %s", result)
+	payload.Message = "Reservation Service!: Reservations fetched successfully"
+	payload.Data = ReservationList{
+		Reservations: reservationsFromProto(result.GetReservations()),
+		Page:         int(result.GetPage()),
+		PageSize:     int(result.GetPageSize()),
+		Total:        int(result.GetTotal()),
+	}
 	app.writeJSON(w, http.StatusOK, payload)
 }
 
+func (app *Config) updateReservation(w http.ResponseWriter, r *http.Request, caller Caller, rd ReservationData) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.UpdateReservation(ctx, &reservationpb.ReservationRequest{
+		Caller: caller.proto(),
+		Reservation: reservationToProto(ReservationData{
+			ReservationID:   rd.ReservationID,
+			UserId:          rd.UserId,
+			Count:           rd.Count,
+			ReservationTime: rd.ReservationTime,
+			Remarks:         rd.Remarks,
+		}),
+	})
+	if err != nil {
+		log.Println("Error updating reservation via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error updating reservation")
+		return
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = fmt.Sprintf("Reservation Service!: %s", result.GetMessage())
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) cancelReservation(w http.ResponseWriter, r *http.Request, caller Caller, rd ReservationData) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.CancelReservation(ctx, &reservationpb.ReservationRequest{
+		Caller: caller.proto(),
+		Reservation: reservationToProto(ReservationData{
+			ReservationID: rd.ReservationID,
+			UserId:        rd.UserId,
+		}),
+	})
+	if err != nil {
+		log.Println("Error cancelling reservation via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error cancelling reservation")
+		return
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = fmt.Sprintf("Reservation Service!: %s", result.GetMessage())
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) availability(w http.ResponseWriter, r *http.Request, reservationReq ReservationRequest) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.Availability(ctx, &reservationpb.AvailabilityRequest{
+		RestaurantId: reservationReq.ReservationData.RestaurantID,
+		Date:         reservationReq.Date,
+		PartySize:    int32(reservationReq.ReservationData.Count),
+	})
+	if err != nil {
+		log.Println("Error getting availability via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error getting availability")
+		return
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = "Reservation Service!: Availability fetched successfully"
+	payload.Data = Availability{
+		RestaurantID: result.GetRestaurantId(),
+		Date:         result.GetDate(),
+		PartySize:    int(result.GetPartySize()),
+		Slots:        append([]string{}, result.GetSlots()...),
+	}
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// reservationErrorJSON passes known reservation service errors back to the caller and
+// hides everything else behind fallback, with the status upstreamStatus picks
+func (app *Config) reservationErrorJSON(w http.ResponseWriter, err error, fallback string) {
+	if app.unavailableJSON(w, err) {
+		return
+	}
+
+	if st, ok := status.FromError(err); ok {
+		if code, ok := reservationRPCStatuses[st.Code()]; ok {
+			app.errorJSON(w, errors.New(st.Message()), code)
+			return
+		}
+	}
+
+	app.errorJSON(w, errors.New(fallback), upstreamStatus(err))
+}
+
+// authenticatedUser returns the caller from the request's access token, writing an
+// error response if there is no valid token
+func (app *Config) authenticatedUser(w http.ResponseWriter, r *http.Request) (Caller, bool) {
+	tokenString, err := extractToken(r)
+	if err != nil {
+		log.Printf("Error extracting token: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("invalid token"), unauthorizedStatus(r))
+		return Caller{}, false
+	}
+
+	caller, err := app.verifyJWT(tokenString)
+	if err != nil {
+		log.Printf("Error verifying JWT: %v\n", err)
+		app.errorJSON(w, fmt.Errorf("unauthorized"), unauthorizedStatus(r))
+		return Caller{}, false
+	}
+
+	return caller, true
+}
+
+// clientIP returns the address the request came from. Forwarding headers are not
+// trusted since the broker is the edge service.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
 // ExtractToken extracts and returns the JWT token from the Authorization header
 func extractToken(r *http.Request) (string, error) {
 	// Fetch the Authorization header
@@ -214,20 +692,18 @@ func extractToken(r *http.Request) (string, error) {
 	return tokenParts[1], nil
 }
 
-// generateToken generates a JWT token
-func generateToken(id string) (string, error) {
+// generateToken generates a JWT token signed with the current key of the key set
+func (app *Config) generateToken(id string, role string) (string, error) {
 	// Create the claims
 	claims := jwt.MapClaims{
-		"id":  id,
-		"exp": time.Now().Add(time.Hour * 1).Unix(), // Expiration time
-		"iat": time.Now().Unix(),                    // Issued At
+		"id":   id,
+		"role": role,
+		"exp":  time.Now().Add(accessTokenTTL).Unix(), // Expiration time
+		"iat":  time.Now().Unix(),                     // Issued At
 	}
 
-	// Create a new token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString(mySigningKey)
+	// Sign the token, the key set adds the kid header
+	tokenString, err := app.Keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -235,18 +711,12 @@ func generateToken(id string) (string, error) {
 	return tokenString, nil
 }
 
-// VerifyJWT verifies the token and returns the claims (fullName and email in this case)
-func verifyJWT(tokenString string) (string, error) {
-	// Parse the token and validate it with the signing key
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the token method is what we expect (HS256)
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return mySigningKey, nil
-	})
+// VerifyJWT verifies the token and returns the caller (user id and role) it was issued to
+func (app *Config) verifyJWT(tokenString string) (Caller, error) {
+	// Parse the token and validate it with the key named by its kid header
+	token, err := jwt.Parse(tokenString, app.Keys.Keyfunc)
 	if err != nil {
-		return "", err
+		return Caller{}, err
 	}
 
 	// Return the token if it's valid
@@ -254,18 +724,31 @@ func verifyJWT(tokenString string) (string, error) {
 		// Get the claims (assuming they are stored in MapClaims)
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			return "", fmt.Errorf("invalid claims")
+			return Caller{}, fmt.Errorf("invalid claims")
 		}
 
 		// Retrieve user id from the claims
 		id, idOk := claims["id"].(string)
 
 		if !idOk {
-			return "", fmt.Errorf("userId not found in token")
+			return Caller{}, fmt.Errorf("userId not found in token")
 		}
 
-		// Return the userID
-		return id, nil
+		// tokens issued before roles existed are treated as customers
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = roleCustomer
+		}
+
+		return Caller{UserID: id, Role: role}, nil
 	}
-	return "", fmt.Errorf("invalid token")
+	return Caller{}, fmt.Errorf("invalid token")
+}
+
+// JWKS publishes the public keys tokens are verified with
+func (app *Config) JWKS(w http.ResponseWriter, r *http.Request) {
+	headers := http.Header{}
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	_ = app.writeJSON(w, http.StatusOK, map[string][]JWK{"keys": app.Keys.JWKS()}, headers)
 }
@@ -9,18 +9,32 @@ import (
 
 type jsonResponse struct {
 	Error   bool   `json:"error"`
+	Code    string `json:"code,omitempty"`
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
 }
 
 type authResponse struct {
 	Error   bool          `json:"error"`
+	Code    string        `json:"code,omitempty"`
 	Message string        `json:"message"`
 	Data    LoginResponse `json:"data,omitempty"`
 }
 
 type LoginResponse struct {
-	ID string `json:"id"`
+	ID           string `json:"id"`
+	Role         string `json:"role,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	TwoFactorRequired bool     `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string   `json:"challengeToken,omitempty"`
+	Secret            string   `json:"secret,omitempty"`
+	OtpauthURI        string   `json:"otpauthURI,omitempty"`
+	RecoveryCodes     []string `json:"recoveryCodes,omitempty"`
+
+	Profile *Profile `json:"profile,omitempty"`
+
+	AuthorizationURL string `json:"authorizationURL,omitempty"`
 }
 
 func (app *Config) readJSON(w http.ResponseWriter, r *http.Request, data any) error {
@@ -77,3 +91,23 @@ func (app *Config) errorJSON(w http.ResponseWriter, err error, status ...int) er
 
 	return app.writeJSON(w, statusCode, payload)
 }
+
+// validationErrorJSON writes a 422 response listing the problem with each field
+func (app *Config) validationErrorJSON(w http.ResponseWriter, message string, errs fieldErrors) error {
+	var payload jsonResponse
+	payload.Error = true
+	payload.Message = message
+	payload.Data = errs
+
+	return app.writeJSON(w, http.StatusUnprocessableEntity, payload)
+}
+
+// errorCodeJSON is errorJSON with a machine readable error code
+func (app *Config) errorCodeJSON(w http.ResponseWriter, err error, code string, status int) error {
+	var payload jsonResponse
+	payload.Error = true
+	payload.Code = code
+	payload.Message = err.Error()
+
+	return app.writeJSON(w, status, payload)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// signingKey is one key of the key set. Private is nil for keys that are only kept
+// around to verify tokens issued before a rotation.
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the key used to sign new tokens and every key tokens are still
+// verified with, indexed by kid.
+//
+// Keys are read from a directory: "<kid>.pem" holds a PKCS#8 RSA or Ed25519 private
+// key, "<kid>.pub.pem" a PKIX public key of a retired key. The signing key is the one
+// named by JWT_SIGNING_KID, or else the private key with the greatest kid, so naming
+// keys by date rotates them in order.
+type KeySet struct {
+	mu      sync.RWMutex
+	dir     string
+	signKID string
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+// JWK is one entry of the JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// loadKeySet reads the keys in dir. Without a directory an ephemeral Ed25519 key is
+// generated, which is only good for a single local instance.
+func loadKeySet(dir, signKID string) (*KeySet, error) {
+	ks := &KeySet{dir: dir, signKID: signKID}
+
+	if dir == "" {
+		log.Println("JWT_KEY_DIR not set, generating an ephemeral signing key")
+
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		key := &signingKey{ID: "ephemeral", Method: jwt.SigningMethodEdDSA, Private: private, Public: public}
+		ks.signing = key
+		ks.keys = map[string]*signingKey{key.ID: key}
+
+		return ks, nil
+	}
+
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// Reload re-reads the key directory, so new keys can be added and old ones retired
+// without a restart
+func (ks *KeySet) Reload() error {
+	if ks.dir == "" {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*signingKey{}
+	var privateIDs []string
+
+	for _, file := range files {
+		key, err := readKeyFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		if existing, ok := keys[key.ID]; ok && existing.Private != nil {
+			continue
+		}
+		keys[key.ID] = key
+
+		if key.Private != nil {
+			privateIDs = append(privateIDs, key.ID)
+		}
+	}
+
+	if len(privateIDs) == 0 {
+		return fmt.Errorf("no private key found in %s", ks.dir)
+	}
+
+	signKID := ks.signKID
+	if signKID == "" {
+		sort.Strings(privateIDs)
+		signKID = privateIDs[len(privateIDs)-1]
+	}
+
+	signing, ok := keys[signKID]
+	if !ok || signing.Private == nil {
+		return fmt.Errorf("signing key %q not found in %s", signKID, ks.dir)
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.signing = signing
+	ks.mu.Unlock()
+
+	log.Printf("Loaded %d JWT keys, signing with kid: %s\n", len(keys), signKID)
+
+	return nil
+}
+
+// Sign signs the claims with the current signing key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	key := ks.signing
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc picks the verification key by the token's kid header, and makes sure the
+// token was signed with the algorithm that key belongs to
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	ks.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.Public, nil
+}
+
+// JWKS returns the public keys in JSON Web Key format
+func (ks *KeySet) JWKS() []JWK {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	jwks := []JWK{}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+
+	return jwks
+}
+
+func readKeyFile(file string) (*signingKey, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	name := filepath.Base(file)
+
+	if strings.HasSuffix(name, ".pub.pem") {
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return newSigningKey(strings.TrimSuffix(name, ".pub.pem"), nil, public)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+
+	return newSigningKey(strings.TrimSuffix(name, ".pem"), private, signer.Public())
+}
+
+func newSigningKey(kid string, private crypto.PrivateKey, public crypto.PublicKey) (*signingKey, error) {
+	key := &signingKey{ID: kid, Private: private, Public: public}
+
+	switch public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	return key, nil
+}
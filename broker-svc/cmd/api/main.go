@@ -1,19 +1,104 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	loggerpb "proto/logger"
+	reservationpb "proto/reservation"
+	"proto/rpcclient"
+	"proto/telemetry"
 )
 
 const webPort = "8888"
 
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 15 * time.Second
+
 // Config struct to hold app configuration and methods
-type Config struct{}
+type Config struct {
+	Keys *KeySet
+	API  *OpenAPI
+
+	// Auth guards the calls to auth-svc; Dependencies lists every guarded service for
+	// the diagnostics endpoint
+	Auth         *Dependency
+	Dependencies []*Dependency
+
+	Limiter *RateLimiter
+}
 
 func main() {
+	shutdownTracing, err := telemetry.Setup(context.Background(), "broker-svc")
+	if err != nil {
+		log.Fatal("Error setting up tracing: ", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Println("Error flushing traces: ", err)
+		}
+	}()
+
+	keys, err := loadKeySet(os.Getenv("JWT_KEY_DIR"), os.Getenv("JWT_SIGNING_KID"))
+	if err != nil {
+		log.Fatal("Error loading JWT keys: ", err)
+	}
+
+	rpcTLS, err = loadRPCTLS()
+	if err != nil {
+		log.Fatal("Error loading RPC certificates: ", err)
+	}
+	authClient = newAuthClient(rpcTLS)
+
+	authDep := newDependency("auth-svc", "AUTH_SVC", unhealthyHTTP)
+	reservationDep := newDependency("reservation-svc", "RESERVATION_SVC", unhealthyRPC)
+	loggerDep := newDependency("logger-svc", "LOGGER_SVC", unhealthyRPC)
+
+	reservationRPC, err := rpcclient.New(rpcclient.Config{Addr: "reservation-svc:50002", TLS: rpcTLS})
+	if err != nil {
+		log.Fatal("Error setting up reservation gRPC client: ", err)
+	}
+	defer reservationRPC.Close()
+	reservationClient = reservationpb.NewReservationServiceClient(reservationDep.guard(reservationRPC, idempotentReservationRPCs))
+
+	loggerRPC, err := rpcclient.New(rpcclient.Config{Addr: "logger-svc:50001", TLS: rpcTLS})
+	if err != nil {
+		log.Fatal("Error setting up logger gRPC client: ", err)
+	}
+	defer loggerRPC.Close()
+	loggerClient = loggerpb.NewLoggerServiceClient(loggerDep.guard(loggerRPC, idempotentLoggerRPCs))
+
 	// Initialize the app configuration
-	app := &Config{}
+	app := &Config{
+		Keys:         keys,
+		Auth:         authDep,
+		Dependencies: []*Dependency{authDep, reservationDep, loggerDep},
+	}
+
+	app.Limiter, err = newRateLimiter()
+	if err != nil {
+		log.Fatal("Error setting up the rate limiter: ", err)
+	}
+	defer app.Limiter.Close()
+
+	app.API, err = app.buildOpenAPI()
+	if err != nil {
+		log.Fatal("Error building the OpenAPI document: ", err)
+	}
+
+	// Reload the keys on SIGHUP to rotate them without downtime
+	go app.reloadKeysOnSignal()
 
 	// Set up the server
 	srv := &http.Server{
@@ -23,9 +108,83 @@ func main() {
 
 	log.Println("Broker service started on port: ", webPort)
 
+	// Finish the requests in flight on SIGINT or SIGTERM before the RPC clients close
+	stopped := shutdownOnSignal(srv)
+
 	// Run the server
-	err := srv.ListenAndServe()
-	if err != nil {
+	err = srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Panic(err)
 	}
+
+	<-stopped
+}
+
+// shutdownOnSignal stops srv gracefully on SIGINT or SIGTERM. The returned channel is
+// closed once the requests in flight are done, or shutdownTimeout passed.
+func shutdownOnSignal(srv *http.Server) <-chan struct{} {
+	stopped := make(chan struct{})
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		log.Println("Shutting down broker service")
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Println("Error shutting down the server: ", err)
+		}
+		close(stopped)
+	}()
+
+	return stopped
+}
+
+func (app *Config) reloadKeysOnSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	for range sig {
+		if err := app.Keys.Reload(); err != nil {
+			log.Println("Error reloading JWT keys, keeping the current ones: ", err)
+		}
+	}
+}
+
+// durationFromEnv reads a duration such as "5s" from the environment, falling back
+// to def when the variable is unset or invalid
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s\n", key, value, def)
+		return def
+	}
+
+	return d
+}
+
+// intFromEnv reads a positive integer from the environment, falling back to def when
+// the variable is unset or invalid
+func intFromEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d\n", key, value, def)
+		return def
+	}
+
+	return n
 }
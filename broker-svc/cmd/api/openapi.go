@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// The OpenAPI document is built when the broker starts: the schemas are generated
+// from the Go types the handlers decode into, and the paths from the REST route
+// table, so the document can't drift from the code. Requests are validated against
+// it before they reach the handlers.
+
+// maxBodyBytes is the largest request body accepted, as in readJSON
+const maxBodyBytes = 1048576
+
+// the actions the /handle envelope takes, by request type
+var envelopeActions = map[string][]string{
+	"RequestPayload": {"auth", "reserve", "restaurant"},
+	"AuthRequest": {
+		"signup", "login", "refresh", "logout", "request_password_reset", "reset_password",
+		"verify_email", "resend_verification", "2fa_enroll", "2fa_confirm", "2fa_disable",
+		"2fa_verify", "set_role", "get_profile", "update_profile", "change_email",
+		"change_password", "oidc_start", "oidc_callback", "delete_account", "export_data",
+	},
+	"ReservationRequest": {"add", "get", "list", "update", "cancel", "availability"},
+}
+
+// queryParameterSchemas are the types of the REST routes' query parameters
+var queryParameterSchemas = map[string]func() *openapi3.Schema{
+	"restaurantID": openapi3.NewStringSchema,
+	"page":         func() *openapi3.Schema { return openapi3.NewIntegerSchema().WithMin(1) },
+	"pageSize":     func() *openapi3.Schema { return openapi3.NewIntegerSchema().WithMin(1) },
+	"partySize": func() *openapi3.Schema {
+		return openapi3.NewIntegerSchema().WithMin(minPartySize).WithMax(maxPartySize)
+	},
+	"date": func() *openapi3.Schema { return openapi3.NewStringSchema().WithFormat("date") },
+}
+
+// idempotencyKeyParameter documents the Idempotency-Key header of the calls that
+// create reservations
+func idempotencyKeyParameter() *openapi3.Parameter {
+	p := openapi3.NewHeaderParameter(idempotencyKeyHeader).
+		WithSchema(openapi3.NewStringSchema().WithMinLength(1).WithMaxLength(maxIdempotencyKeyLength))
+	p.Description = "Repeating the request with the same key returns the first result instead of booking again"
+	return p
+}
+
+var pathParameterPattern = regexp.MustCompile(`{([^}]+)}`)
+
+// OpenAPI is the broker's API description and the router requests are matched
+// against for validation
+type OpenAPI struct {
+	Doc    *openapi3.T
+	Router routers.Router
+
+	json []byte
+}
+
+// buildOpenAPI generates and validates the API description
+func (app *Config) buildOpenAPI() (*OpenAPI, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       "Booking System Broker",
+			Version:     "1.0.0",
+			Description: "The REST API under /v1, and the /handle action envelope it shares its handlers with.",
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{},
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearerAuth": &openapi3.SecuritySchemeRef{Value: openapi3.NewJWTSecurityScheme()},
+			},
+		},
+	}
+
+	schemaFor := func(v any) (*openapi3.SchemaRef, error) {
+		return openapi3gen.NewSchemaRefForValue(v, doc.Components.Schemas,
+			openapi3gen.CreateComponentSchemas(openapi3gen.ExportComponentSchemasOptions{
+				ExportComponentSchemas: true,
+				ExportTopLevelSchema:   true,
+			}),
+			openapi3gen.SchemaCustomizer(nullableReferences),
+		)
+	}
+
+	errorSchema, err := schemaFor(jsonResponse{})
+	if err != nil {
+		return nil, err
+	}
+
+	// the envelope
+	envelope, err := schemaFor(RequestPayload{})
+	if err != nil {
+		return nil, err
+	}
+	handle := openapi3.NewOperation()
+	handle.Summary = "Run an action through the action envelope"
+	handle.OperationID = "handle"
+	handle.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(envelope)}
+	handle.AddParameter(idempotencyKeyParameter())
+	handle.AddResponse(http.StatusOK, responseFor("Action done", nil))
+	handle.AddResponse(http.StatusAccepted, responseFor("Action done", nil))
+	addErrorResponses(handle, errorSchema)
+	doc.AddOperation("/handle", http.MethodPost, handle)
+
+	jwks := openapi3.NewOperation()
+	jwks.Summary = "The public keys access tokens are signed with"
+	jwks.OperationID = "jwks"
+	jwks.AddResponse(http.StatusOK, openapi3.NewResponse().WithDescription("JSON Web Key Set").WithJSONSchema(openapi3.NewObjectSchema()))
+	doc.AddOperation("/.well-known/jwks.json", http.MethodGet, jwks)
+
+	spec := openapi3.NewOperation()
+	spec.Summary = "This document"
+	spec.OperationID = "openapi"
+	spec.AddResponse(http.StatusOK, openapi3.NewResponse().WithDescription("OpenAPI document").WithJSONSchema(openapi3.NewObjectSchema()))
+	doc.AddOperation("/openapi.json", http.MethodGet, spec)
+
+	dependencies, err := schemaFor([]DependencyStatus{})
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := openapi3.NewOperation()
+	diagnostics.Summary = "The circuit breaker state of the services the broker calls"
+	diagnostics.OperationID = "diagnosticsDependencies"
+	diagnostics.AddResponse(http.StatusOK, responseFor("Dependencies", dependencies))
+	doc.AddOperation("/diagnostics/dependencies", http.MethodGet, diagnostics)
+
+	// the REST routes
+	for _, route := range app.restRoutes() {
+		op := openapi3.NewOperation()
+		op.Summary = route.Summary
+		op.OperationID = operationID(route.Method, route.Pattern)
+
+		for _, match := range pathParameterPattern.FindAllStringSubmatch(route.Pattern, -1) {
+			op.AddParameter(openapi3.NewPathParameter(match[1]).WithSchema(openapi3.NewStringSchema()))
+		}
+		for _, name := range route.Query {
+			newSchema, ok := queryParameterSchemas[name]
+			if !ok {
+				return nil, errors.New("no schema for query parameter " + name)
+			}
+			op.AddParameter(openapi3.NewQueryParameter(name).WithSchema(newSchema()))
+		}
+		if route.Keyed {
+			op.AddParameter(idempotencyKeyParameter())
+		}
+
+		if route.Body != nil {
+			body, err := schemaFor(route.Body)
+			if err != nil {
+				return nil, err
+			}
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithJSONSchemaRef(body)}
+		}
+
+		var reply *openapi3.SchemaRef
+		if route.Reply != nil {
+			if reply, err = schemaFor(route.Reply); err != nil {
+				return nil, err
+			}
+		}
+		op.AddResponse(route.Status, responseFor(http.StatusText(route.Status), reply))
+		if route.Keyed {
+			op.AddResponse(http.StatusOK, responseFor("Replay of an earlier request with the same Idempotency-Key", nil))
+		}
+		if route.Challenge {
+			challenge, err := schemaFor(TwoFactorChallenge{})
+			if err != nil {
+				return nil, err
+			}
+			op.AddResponse(http.StatusAccepted, responseFor("Two-factor authentication required, finish with /v1/auth/2fa/verify", challenge))
+		}
+		addErrorResponses(op, errorSchema)
+
+		if route.Auth {
+			op.Security = openapi3.NewSecurityRequirements().With(openapi3.NewSecurityRequirement().Authenticate("bearerAuth"))
+			op.AddResponse(http.StatusUnauthorized, openapi3.NewResponse().WithDescription("Missing or invalid access token").WithJSONSchemaRef(errorSchema))
+			op.AddResponse(http.StatusForbidden, openapi3.NewResponse().WithDescription("Not allowed for the user's role").WithJSONSchemaRef(errorSchema))
+		}
+
+		doc.AddOperation("/v1"+route.Pattern, route.Method, op)
+	}
+
+	for name, actions := range envelopeActions {
+		setActionEnum(doc, name, actions)
+	}
+	restaurantActions := make([]string, 0, len(restaurantActionPermissions))
+	for action := range restaurantActionPermissions {
+		restaurantActions = append(restaurantActions, action)
+	}
+	sort.Strings(restaurantActions)
+	setActionEnum(doc, "RestaurantRequest", restaurantActions)
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// loading the document back resolves the component references, which validation
+	// needs
+	doc, err = openapi3.NewLoader().LoadFromData(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenAPI{Doc: doc, Router: router, json: out}, nil
+}
+
+// nullableReferences lets clients send null for lists, maps and pointers, which the
+// JSON decoder accepts as empty
+func nullableReferences(name string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Pointer, reflect.Interface:
+		schema.Nullable = true
+	}
+	return nil
+}
+
+func setActionEnum(doc *openapi3.T, schemaName string, actions []string) {
+	ref, ok := doc.Components.Schemas[schemaName]
+	if !ok || ref.Value == nil {
+		return
+	}
+	action, ok := ref.Value.Properties["action"]
+	if !ok || action.Value == nil {
+		return
+	}
+
+	for _, a := range actions {
+		action.Value.Enum = append(action.Value.Enum, a)
+	}
+}
+
+// responseFor is the envelope every response comes in, with data of the given schema
+func responseFor(description string, data *openapi3.SchemaRef) *openapi3.Response {
+	schema := openapi3.NewObjectSchema().
+		WithProperty("error", openapi3.NewBoolSchema()).
+		WithProperty("message", openapi3.NewStringSchema())
+	if data != nil {
+		schema.WithPropertyRef("data", data)
+	}
+
+	return openapi3.NewResponse().WithDescription(description).WithJSONSchema(schema)
+}
+
+func addErrorResponses(op *openapi3.Operation, errorSchema *openapi3.SchemaRef) {
+	op.AddResponse(http.StatusBadRequest, openapi3.NewResponse().WithDescription("Invalid request").WithJSONSchemaRef(errorSchema))
+	op.AddResponse(http.StatusUnprocessableEntity, openapi3.NewResponse().WithDescription("Invalid fields, listed in data").WithJSONSchemaRef(errorSchema))
+
+	unavailable := openapi3.NewResponse().WithDescription("A service the request needs is unavailable").WithJSONSchemaRef(errorSchema)
+	unavailable.Headers = openapi3.Headers{
+		"Retry-After": &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+			Description: "Seconds until the service is tried again",
+			Schema:      openapi3.NewIntegerSchema().NewRef(),
+		}}},
+	}
+	op.AddResponse(http.StatusServiceUnavailable, unavailable)
+	op.AddResponse(http.StatusInternalServerError, openapi3.NewResponse().WithDescription("Internal error").WithJSONSchemaRef(errorSchema))
+	op.AddResponse(http.StatusBadGateway, openapi3.NewResponse().WithDescription("A service the request needs failed or couldn't be reached").WithJSONSchemaRef(errorSchema))
+	op.AddResponse(http.StatusGatewayTimeout, openapi3.NewResponse().WithDescription("A service the request needs didn't answer in time").WithJSONSchemaRef(errorSchema))
+
+	limited := openapi3.NewResponse().WithDescription("Too many requests from the client, the user, or for the action").WithJSONSchemaRef(errorSchema)
+	limited.Headers = openapi3.Headers{
+		"Retry-After": &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+			Description: "Seconds until the request is allowed again",
+			Schema:      openapi3.NewIntegerSchema().NewRef(),
+		}}},
+	}
+	op.AddResponse(http.StatusTooManyRequests, limited)
+}
+
+// operationID names an operation after its route, such as "getReservationsId"
+func operationID(method, pattern string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	for _, part := range strings.FieldsFunc(pattern, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return b.String()
+}
+
+// OpenAPIDocument serves the API description
+func (app *Config) OpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(app.API.json)
+}
+
+// validateRequests rejects requests that don't match the API description with a 422
+// listing the offending fields. Unknown routes are left to the router.
+func (app *Config) validateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := app.API.Router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+		// the broker only speaks JSON, so clients that don't say so aren't turned away
+		if r.Header.Get("Content-Type") == "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError: true,
+				// tokens are checked by the handlers, which know the roles
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			if undecodableBody(err) {
+				app.errorJSON(w, errors.New("request body must be valid JSON"))
+				return
+			}
+
+			errs := fieldErrors{}
+			collectFieldErrors(err, "body", errs)
+			app.validationErrorJSON(w, "invalid request", errs)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// collectFieldErrors turns validation errors into field errors keyed by the dotted
+// path of the field, such as "reservation.reservationData.count", or by the name of
+// the parameter
+func collectFieldErrors(err error, field string, errs fieldErrors) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			collectFieldErrors(inner, field, errs)
+		}
+
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		switch e.Err.(type) {
+		case openapi3.MultiError, *openapi3.SchemaError:
+			collectFieldErrors(e.Err, field, errs)
+			return
+		}
+		msg := e.Reason
+		if e.Err != nil {
+			msg = e.Err.Error()
+		}
+		errs[field] = msg
+
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			path := strings.Join(pointer, ".")
+			if field != "body" {
+				path = field + "." + path
+			}
+			field = path
+		}
+		errs[field] = schemaErrorMessage(e)
+
+	default:
+		errs[field] = err.Error()
+	}
+}
+
+// schemaErrorMessage words format errors the way the handlers' own validation does,
+// rather than quoting the format's pattern
+func schemaErrorMessage(e *openapi3.SchemaError) string {
+	if e.SchemaField == "format" && e.Schema != nil {
+		switch e.Schema.Format {
+		case "date-time":
+			return "must be an RFC 3339 time with a timezone, e.g. 2025-05-01T19:30:00+02:00"
+		case "date":
+			return "date must be formatted as YYYY-MM-DD"
+		}
+	}
+
+	return e.Reason
+}
+
+// undecodableBody reports whether err is about a request body that isn't JSON at all,
+// which is a bad request rather than invalid fields
+func undecodableBody(err error) bool {
+	var reqErr *openapi3filter.RequestError
+	var parseErr *openapi3filter.ParseError
+	return errors.As(err, &reqErr) && reqErr.RequestBody != nil && errors.As(reqErr.Err, &parseErr)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json with the generated document")
+
+const openAPIGolden = "testdata/openapi.json"
+
+// TestOpenAPIDocument compares the generated document with the committed one, so a
+// change to the API shows up in review. Run with -update to accept the change.
+func TestOpenAPIDocument(t *testing.T) {
+	app := &Config{}
+
+	api, err := app.buildOpenAPI()
+	if err != nil {
+		t.Fatalf("buildOpenAPI: %v", err)
+	}
+
+	var got bytes.Buffer
+	if err = json.Indent(&got, api.json, "", "  "); err != nil {
+		t.Fatalf("indenting the document: %v", err)
+	}
+	got.WriteByte('\n')
+
+	if *update {
+		if err = os.MkdirAll(filepath.Dir(openAPIGolden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(openAPIGolden, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(openAPIGolden)
+	if err != nil {
+		t.Fatalf("reading %s: %v (run go test -run TestOpenAPIDocument -update to create it)", openAPIGolden, err)
+	}
+
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("the generated OpenAPI document differs from %s; check the change and run go test -run TestOpenAPIDocument -update to accept it", openAPIGolden)
+	}
+}
+
+// TestOpenAPIRoutes checks every route of the REST table and every operation of the
+// document against the routes the router actually serves
+func TestOpenAPIRoutes(t *testing.T) {
+	app := &Config{}
+
+	api, err := app.buildOpenAPI()
+	if err != nil {
+		t.Fatalf("buildOpenAPI: %v", err)
+	}
+
+	router, ok := app.routes().(chi.Routes)
+	if !ok {
+		t.Fatal("routes() is not a chi router")
+	}
+
+	served := map[string]bool{}
+	err = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		served[method+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking the router: %v", err)
+	}
+
+	documented := map[string]bool{}
+	for path, item := range api.Doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	tabled := map[string]bool{}
+	for _, route := range app.restRoutes() {
+		key := route.Method + " /v1" + route.Pattern
+		if tabled[key] {
+			t.Errorf("%s is in restRoutes twice", key)
+		}
+		tabled[key] = true
+
+		if !served[key] {
+			t.Errorf("%s is in restRoutes but not served", key)
+		}
+		if !documented[key] {
+			t.Errorf("%s is in restRoutes but not documented", key)
+		}
+	}
+
+	for _, key := range sortedKeys(documented) {
+		if !served[key] {
+			t.Errorf("%s is documented but not served", key)
+		}
+	}
+
+	for _, key := range sortedKeys(served) {
+		if strings.Contains(key, " /v1/") && !tabled[key] {
+			t.Errorf("%s is served but not in restRoutes", key)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
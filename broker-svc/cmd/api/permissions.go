@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// roles carried in the role claim of access tokens, as stored in auth-svc
+const (
+	roleCustomer = "customer"
+	roleStaff    = "restaurant_staff"
+	roleAdmin    = "admin"
+)
+
+// Caller is the user a request is made by, read from a verified access token. It is
+// passed on to the reservation service, which scopes what the caller can see by it.
+type Caller struct {
+	UserID string
+	Role   string
+}
+
+type permission string
+
+const (
+	// manage one's own reservations
+	permOwnReservations permission = "reservations:own"
+	// see the reservations of a restaurant; the reservation service checks that staff
+	// are assigned to it
+	permRestaurantReservations permission = "reservations:restaurant"
+	permReadRestaurants        permission = "restaurants:read"
+	permWriteRestaurants       permission = "restaurants:write"
+	permManageStaff            permission = "restaurants:staff"
+	permManageRoles            permission = "users:roles"
+)
+
+// rolePermissions lists what each role may do. Unknown roles get nothing.
+var rolePermissions = map[string][]permission{
+	roleCustomer: {permOwnReservations, permReadRestaurants},
+	roleStaff:    {permOwnReservations, permReadRestaurants, permRestaurantReservations},
+	roleAdmin: {permOwnReservations, permReadRestaurants, permRestaurantReservations,
+		permWriteRestaurants, permManageStaff, permManageRoles},
+}
+
+var errForbidden = errors.New("forbidden")
+
+// can reports whether the caller's role grants the permission
+func (c Caller) can(p permission) bool {
+	for _, granted := range rolePermissions[c.Role] {
+		if granted == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorize checks the caller's permission, writing a 403 response if it is missing
+func (app *Config) authorize(w http.ResponseWriter, c Caller, p permission) bool {
+	if c.can(p) {
+		return true
+	}
+
+	log.Printf("User with id: %v and role: %v denied %v\n", c.UserID, c.Role, p)
+	app.errorJSON(w, errForbidden, http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Requests are limited with token buckets: one per client IP, one per authenticated
+// user, and one per user or IP for each action in actionRates. A bucket holds up to
+// Limit tokens and refills them evenly over Period; a request spends one token from
+// each bucket it falls in.
+
+// codeRateLimited is the error code of a request refused by the rate limiter, the same
+// auth-svc uses for its own limits
+const codeRateLimited = "rate_limited"
+
+// default rates, overridden with RATE_LIMIT_IP, RATE_LIMIT_USER and
+// RATE_LIMIT_<ACTION> such as RATE_LIMIT_AUTH_LOGIN
+var (
+	defaultIPRate   = Rate{Limit: 300, Period: time.Minute}
+	defaultUserRate = Rate{Limit: 120, Period: time.Minute}
+
+	defaultActionRates = map[string]Rate{
+		"auth/login":  {Limit: 10, Period: time.Minute},
+		"auth/signup": {Limit: 10, Period: time.Hour},
+		"reserve/add": {Limit: 30, Period: time.Hour},
+	}
+)
+
+// Rate is the size of a token bucket and how long it takes to refill. A zero Limit
+// turns the bucket off.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// parseRate reads a rate such as "10/1m", or "off"
+func parseRate(s string) (Rate, error) {
+	if s == "off" {
+		return Rate{}, nil
+	}
+
+	limit, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, errors.New("want <requests>/<period>")
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return Rate{}, errors.New("requests must be a positive number")
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rate{}, errors.New("period must be a positive duration")
+	}
+
+	return Rate{Limit: n, Period: d}, nil
+}
+
+func rateFromEnv(key string, def Rate) Rate {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	rate, err := parseRate(value)
+	if err != nil {
+		log.Printf("Invalid %s %q (%v), using %d/%s\n", key, value, err, def.Limit, def.Period)
+		return def
+	}
+
+	return rate
+}
+
+// RateLimitStore keeps the token buckets. Take spends a token from the bucket at key,
+// creating it full if it doesn't exist, and returns the tokens left. allowed is false
+// if there was no whole token to spend.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rate Rate, now time.Time) (tokens float64, allowed bool, err error)
+}
+
+// RateLimiter holds the rates and the store of the buckets
+type RateLimiter struct {
+	Store   RateLimitStore
+	IP      Rate
+	User    Rate
+	Actions map[string]Rate
+}
+
+// newRateLimiter sets the limiter up from the environment. The buckets are kept in
+// memory unless RATE_LIMIT_STORE is redis, which shares them between broker replicas
+// through the server at RATE_LIMIT_REDIS_URL.
+func newRateLimiter() (*RateLimiter, error) {
+	limiter := &RateLimiter{
+		IP:      rateFromEnv("RATE_LIMIT_IP", defaultIPRate),
+		User:    rateFromEnv("RATE_LIMIT_USER", defaultUserRate),
+		Actions: map[string]Rate{},
+	}
+	for action, def := range defaultActionRates {
+		key := "RATE_LIMIT_" + strings.ToUpper(strings.ReplaceAll(action, "/", "_"))
+		limiter.Actions[action] = rateFromEnv(key, def)
+	}
+
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		limiter.Store = newMemoryStore()
+	case "redis":
+		opts, err := redis.ParseURL(os.Getenv("RATE_LIMIT_REDIS_URL"))
+		if err != nil {
+			return nil, fmt.Errorf("parsing RATE_LIMIT_REDIS_URL: %w", err)
+		}
+		limiter.Store = newRedisStore(redis.NewClient(opts))
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q, want memory or redis", store)
+	}
+
+	return limiter, nil
+}
+
+// Close releases the store's connections, if it has any
+func (l *RateLimiter) Close() error {
+	if closer, ok := l.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// rateLimitState is a bucket after a request took its token
+type rateLimitState struct {
+	rate    Rate
+	tokens  float64
+	allowed bool
+}
+
+func (s rateLimitState) remaining() int {
+	return int(math.Floor(s.tokens))
+}
+
+// reset is how long until the bucket is full again
+func (s rateLimitState) reset() time.Duration {
+	return time.Duration((float64(s.rate.Limit) - s.tokens) / float64(s.rate.Limit) * float64(s.rate.Period))
+}
+
+// retryAfter is how long until the bucket has a whole token again
+func (s rateLimitState) retryAfter() time.Duration {
+	return time.Duration((1 - s.tokens) / float64(s.rate.Limit) * float64(s.rate.Period))
+}
+
+// take spends a token from the bucket at key. If the store fails, the request is let
+// through: the limiter protects the services, it shouldn't take them down.
+func (l *RateLimiter) take(ctx context.Context, key string, rate Rate) (rateLimitState, bool) {
+	if rate.Limit == 0 {
+		return rateLimitState{}, false
+	}
+
+	tokens, allowed, err := l.Store.Take(ctx, key, rate, time.Now())
+	if err != nil {
+		log.Printf("Error checking rate limit for %s: %v\n", key, err)
+		return rateLimitState{}, false
+	}
+
+	return rateLimitState{rate: rate, tokens: tokens, allowed: allowed}, true
+}
+
+type rateLimitContextKey struct{}
+
+// rateLimitReport is the bucket reported in the RateLimit headers: the one that
+// refused the request, or else the one closest to running out
+type rateLimitReport struct {
+	state *rateLimitState
+}
+
+func (rep *rateLimitReport) add(w http.ResponseWriter, s rateLimitState) {
+	if rep.state != nil && (!rep.state.allowed || (s.allowed && s.remaining() >= rep.state.remaining())) {
+		return
+	}
+	rep.state = &s
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(s.rate.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(s.remaining()))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(s.reset().Seconds()))))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", s.rate.Limit, int(s.rate.Period.Seconds())))
+}
+
+// rateLimit limits the requests of each client IP and of each authenticated user
+func (app *Config) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := &rateLimitReport{}
+		ctx := context.WithValue(r.Context(), rateLimitContextKey{}, report)
+
+		keys := []string{"ip:" + clientIP(r)}
+		rates := []Rate{app.Limiter.IP}
+
+		// an invalid token is refused by the handler, and the request still counts
+		// against the IP
+		if tokenString, err := extractToken(r); err == nil {
+			if caller, err := app.verifyJWT(tokenString); err == nil {
+				keys = append(keys, "user:"+caller.UserID)
+				rates = append(rates, app.Limiter.User)
+			}
+		}
+
+		// a refused request stops at the bucket that refused it, so it doesn't use up
+		// the user's tokens as well
+		for i, key := range keys {
+			state, ok := app.Limiter.take(ctx, key, rates[i])
+			if !ok {
+				continue
+			}
+			report.add(w, state)
+			if !state.allowed {
+				break
+			}
+		}
+
+		if report.state != nil && !report.state.allowed {
+			app.rateLimitedJSON(w, *report.state)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// limitAction spends a token from the subject's bucket for the action, such as
+// "auth/login" for an IP, writing a 429 and returning false if there is none left.
+// Actions without a rate are not limited.
+func (app *Config) limitAction(w http.ResponseWriter, r *http.Request, action, subject string) bool {
+	rate, ok := app.Limiter.Actions[action]
+	if !ok {
+		return true
+	}
+
+	state, ok := app.Limiter.take(r.Context(), "action:"+action+":"+subject, rate)
+	if !ok {
+		return true
+	}
+
+	if report, ok := r.Context().Value(rateLimitContextKey{}).(*rateLimitReport); ok {
+		report.add(w, state)
+	}
+
+	if !state.allowed {
+		app.rateLimitedJSON(w, state)
+		return false
+	}
+
+	return true
+}
+
+func (app *Config) rateLimitedJSON(w http.ResponseWriter, s rateLimitState) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(s.retryAfter())))
+	app.errorCodeJSON(w, errors.New("too many requests, try again later"), codeRateLimited, http.StatusTooManyRequests)
+}
+
+// memoryStore keeps the buckets of a single broker. Buckets that have refilled are
+// dropped every memorySweepInterval.
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled
+}
+
+const memorySweepInterval = time.Minute
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: map[string]*memoryBucket{}, lastSweep: time.Now()}
+}
+
+func (m *memoryStore) Take(_ context.Context, key string, rate Rate, now time.Time) (float64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= memorySweepInterval {
+		for k, b := range m.buckets {
+			if !now.Before(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	limit := float64(rate.Limit)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: limit, updated: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(limit, b.tokens+now.Sub(b.updated).Seconds()*limit/rate.Period.Seconds())
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((limit - b.tokens) / limit * float64(rate.Period)))
+
+	return b.tokens, allowed, nil
+}
+
+// redisStore keeps the buckets in Redis, or a server speaking its protocol, so every
+// broker replica counts against the same buckets. A bucket is a hash refilled and
+// spent by a script, so concurrent requests can't both take the last token, and it
+// expires once it would have refilled.
+type redisStore struct {
+	client *redis.Client
+}
+
+const redisKeyPrefix = "broker:ratelimit:"
+
+var takeScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil or updated == nil then
+	tokens = limit
+	updated = now
+end
+
+tokens = math.min(limit, tokens + math.max(0, now - updated) * limit / period)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((limit - tokens) * period / limit) + 1)
+
+return {allowed, tostring(tokens)}
+`)
+
+func newRedisStore(client *redis.Client) *redisStore {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, rate Rate, now time.Time) (float64, bool, error) {
+	reply, err := takeScript.Run(ctx, s.client, []string{redisKeyPrefix + key},
+		rate.Limit, rate.Period.Milliseconds(), now.UnixMilli(),
+	).Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(reply) != 2 {
+		return 0, false, fmt.Errorf("unexpected reply %v", reply)
+	}
+
+	allowed, _ := reply[0].(int64)
+	tokensReply, _ := reply[1].(string)
+
+	tokens, err := strconv.ParseFloat(tokensReply, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("unexpected tokens %q", tokensReply)
+	}
+
+	return tokens, allowed == 1, nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "10/1m", want: Rate{Limit: 10, Period: time.Minute}},
+		{in: "300/1h30m", want: Rate{Limit: 300, Period: 90 * time.Minute}},
+		{in: "off", want: Rate{}},
+		{in: "10", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "-1/1m", wantErr: true},
+		{in: "10/0s", wantErr: true},
+		{in: "ten/1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRate(%q) error = %v, want error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseRate(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// testStore runs the bucket behaviour every RateLimitStore must have against store
+func testStore(t *testing.T, store RateLimitStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	rate := Rate{Limit: 3, Period: 3 * time.Second}
+	now := time.Now()
+
+	take := func(key string, at time.Time) (float64, bool) {
+		t.Helper()
+
+		tokens, allowed, err := store.Take(ctx, key, rate, at)
+		if err != nil {
+			t.Fatalf("Take(%s): %v", key, err)
+		}
+		return tokens, allowed
+	}
+
+	for i := 1; i <= rate.Limit; i++ {
+		tokens, allowed := take("a", now)
+		if !allowed || tokens != float64(rate.Limit-i) {
+			t.Fatalf("take %d: %v tokens, allowed %v; want %d, true", i, tokens, allowed, rate.Limit-i)
+		}
+	}
+
+	if _, allowed := take("a", now); allowed {
+		t.Fatal("take from an empty bucket was allowed")
+	}
+
+	// other keys have buckets of their own
+	if tokens, allowed := take("b", now); !allowed || tokens != float64(rate.Limit-1) {
+		t.Fatalf("take from another bucket: %v tokens, allowed %v; want %d, true", tokens, allowed, rate.Limit-1)
+	}
+
+	// a token comes back every Period / Limit
+	if tokens, allowed := take("a", now.Add(time.Second)); !allowed || tokens != 0 {
+		t.Fatalf("take after a refill: %v tokens, allowed %v; want 0, true", tokens, allowed)
+	}
+
+	// the bucket never holds more than Limit
+	if tokens, allowed := take("a", now.Add(time.Hour)); !allowed || tokens != float64(rate.Limit-1) {
+		t.Fatalf("take after a long wait: %v tokens, allowed %v; want %d, true", tokens, allowed, rate.Limit-1)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, newMemoryStore())
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := newMemoryStore()
+	rate := Rate{Limit: 2, Period: time.Second}
+	now := time.Now()
+
+	_, _, _ = store.Take(context.Background(), "refilled", rate, now)
+	_, _, _ = store.Take(context.Background(), "busy", rate, now.Add(memorySweepInterval))
+	_, _, _ = store.Take(context.Background(), "busy", rate, now.Add(memorySweepInterval))
+
+	if _, ok := store.buckets["refilled"]; ok {
+		t.Error("a refilled bucket was kept after the sweep")
+	}
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Error("a bucket in use was dropped by the sweep")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	store := newRedisStore(client)
+	t.Cleanup(func() { _ = store.Close() })
+
+	testStore(t, store)
+
+	// buckets expire once they would have refilled
+	if ttl := server.TTL(redisKeyPrefix + "b"); ttl <= 0 || ttl > 3*time.Second {
+		t.Errorf("bucket expires in %v, want within 3s", ttl)
+	}
+}
+
+func TestRedisStoreDown(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	store := newRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1}))
+	t.Cleanup(func() { _ = store.Close() })
+
+	server.Close()
+
+	// the limiter lets requests through when the store fails
+	limiter := &RateLimiter{Store: store}
+	if _, ok := limiter.take(context.Background(), "ip:192.0.2.1", Rate{Limit: 1, Period: time.Minute}); ok {
+		t.Error("take reported a bucket with the store down")
+	}
+}
+
+// newRateLimitedApp returns an app limiting each IP to ipLimit and each user to
+// userLimit requests a minute, and a handler behind its rate limiter
+func newRateLimitedApp(t *testing.T, ipLimit, userLimit int) (*Config, http.Handler) {
+	t.Helper()
+
+	keys, err := loadKeySet("", "")
+	if err != nil {
+		t.Fatalf("loadKeySet: %v", err)
+	}
+
+	app := &Config{
+		Keys: keys,
+		Limiter: &RateLimiter{
+			Store:   newMemoryStore(),
+			IP:      Rate{Limit: ipLimit, Period: time.Minute},
+			User:    Rate{Limit: userLimit, Period: time.Minute},
+			Actions: map[string]Rate{},
+		},
+	}
+
+	return app, app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func serve(handler http.Handler, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	_, handler := newRateLimitedApp(t, 2, 10)
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := serve(handler, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+
+		for header, want := range map[string]string{
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": wantRemaining,
+			"RateLimit-Policy":    "2;w=60",
+		} {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("request %d: %s = %q, want %q", i+1, header, got, want)
+			}
+		}
+		if w.Header().Get("RateLimit-Reset") == "" {
+			t.Errorf("request %d: no RateLimit-Reset", i+1)
+		}
+	}
+
+	w := serve(handler, "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("request over the limit: RateLimit-Remaining = %q, want %q", got, "0")
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("request over the limit: Retry-After = %q, want %q", got, "30")
+	}
+}
+
+func TestRateLimitReportsTheTightestBucket(t *testing.T) {
+	app, handler := newRateLimitedApp(t, 10, 3)
+
+	token, err := app.generateToken("user-1", roleCustomer)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+
+	w := serve(handler, token)
+	if got := w.Header().Get("RateLimit-Limit"); got != "3" {
+		t.Errorf("RateLimit-Limit = %q, want the user bucket's %q", got, "3")
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "2" {
+		t.Errorf("RateLimit-Remaining = %q, want %q", got, "2")
+	}
+}
+
+func TestRateLimitStopsAtFirstRefusal(t *testing.T) {
+	app, handler := newRateLimitedApp(t, 1, 5)
+
+	token, err := app.generateToken("user-1", roleCustomer)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+
+	if w := serve(handler, token); w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d, want %d", w.Code, http.StatusOK)
+	}
+	for i := 0; i < 3; i++ {
+		if w := serve(handler, token); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("request over the IP limit: status %d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+	}
+
+	// only the first request took from the user's bucket
+	tokens, _, err := app.Limiter.Store.Take(context.Background(), "user:user-1", app.Limiter.User, time.Now())
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if tokens < 3 || tokens >= 4 {
+		t.Errorf("user bucket has %v tokens after this take, want 3", tokens)
+	}
+}
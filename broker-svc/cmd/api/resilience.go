@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaults of the settings of each dependency, overridden with <NAME>_TIMEOUT,
+// BREAKER_THRESHOLD and BREAKER_COOLDOWN
+const (
+	defaultDependencyTimeout = 5 * time.Second
+	defaultBreakerThreshold  = 5
+	defaultBreakerCooldown   = 30 * time.Second
+
+	// idempotent calls are retried up to dependencyRetries times, waiting a random
+	// time of up to retryBaseDelay, doubled on every retry and capped at retryMaxDelay
+	dependencyRetries = 2
+	retryBaseDelay    = 100 * time.Millisecond
+	retryMaxDelay     = time.Second
+)
+
+// circuit breaker states
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// CircuitOpenError is returned without calling a dependency while its breaker is open
+type CircuitOpenError struct {
+	Dependency string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s is unavailable", e.Dependency)
+}
+
+// Dependency guards the calls to one downstream service. Each attempt gets Timeout,
+// idempotent calls are retried with jittered backoff, and after Threshold failed calls
+// in a row the breaker opens: calls fail fast for Cooldown, then a single trial call
+// decides whether it closes again. Only failures that point at an unhealthy service,
+// as told by unhealthy, count; a refusal such as "not found" is a healthy answer.
+type Dependency struct {
+	Name      string
+	Timeout   time.Duration
+	Threshold int
+	Cooldown  time.Duration
+
+	unhealthy func(error) bool
+
+	mu          sync.Mutex
+	state       string
+	failures    int
+	openedAt    time.Time
+	trialActive bool
+	lastError   string // see failureKind
+	lastErrorAt time.Time
+}
+
+// DependencyStatus is the state of a dependency's breaker, as shown on the
+// diagnostics endpoint
+type DependencyStatus struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	Timeout             string     `json:"timeout"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	RetryAfterSeconds   int        `json:"retryAfterSeconds,omitempty"`
+	LastError           string     `json:"lastError,omitempty"` // timeout, unavailable or server error
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// newDependency returns the guard of the named service. Its timeout is read from
+// envPrefix_TIMEOUT, such as AUTH_SVC_TIMEOUT.
+func newDependency(name, envPrefix string, unhealthy func(error) bool) *Dependency {
+	return &Dependency{
+		Name:      name,
+		Timeout:   durationFromEnv(envPrefix+"_TIMEOUT", defaultDependencyTimeout),
+		Threshold: intFromEnv("BREAKER_THRESHOLD", defaultBreakerThreshold),
+		Cooldown:  durationFromEnv("BREAKER_COOLDOWN", defaultBreakerCooldown),
+		unhealthy: unhealthy,
+		state:     breakerClosed,
+	}
+}
+
+// Call runs attempt with the dependency's timeout, through its breaker. Idempotent
+// calls are retried while attempts fail for reasons that count against the breaker.
+func (d *Dependency) Call(ctx context.Context, idempotent bool, attempt func(context.Context) error) error {
+	attempts := 1
+	if idempotent {
+		attempts += dependencyRetries
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 && !sleepContext(ctx, retryDelay(i)) {
+			return err
+		}
+
+		if openErr := d.allow(); openErr != nil {
+			return openErr
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, d.Timeout)
+		err = attempt(attemptCtx)
+		cancel()
+
+		if err != nil && (errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled)) {
+			// the caller gave up, which says nothing about the service either way
+			d.abandoned()
+			return err
+		}
+		if err == nil || !d.unhealthy(err) {
+			d.succeeded()
+			return err
+		}
+		d.failed(err)
+	}
+
+	return err
+}
+
+// Status reports the state of the breaker
+func (d *Dependency) Status() DependencyStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := DependencyStatus{
+		Name:                d.Name,
+		State:               d.state,
+		Timeout:             d.Timeout.String(),
+		ConsecutiveFailures: d.failures,
+		LastError:           d.lastError,
+	}
+	if d.state == breakerOpen {
+		s.RetryAfterSeconds = retryAfterSeconds(d.openedAt.Add(d.Cooldown).Sub(time.Now()))
+	}
+	if !d.lastErrorAt.IsZero() {
+		at := d.lastErrorAt
+		s.LastErrorAt = &at
+	}
+
+	return s
+}
+
+// allow returns a CircuitOpenError if the call may not go through. Once the cooldown
+// is over, one trial call is let through while the others keep failing fast.
+func (d *Dependency) allow() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.state {
+	case breakerOpen:
+		if wait := d.openedAt.Add(d.Cooldown).Sub(time.Now()); wait > 0 {
+			return &CircuitOpenError{Dependency: d.Name, RetryAfter: wait}
+		}
+		d.state = breakerHalfOpen
+		d.trialActive = true
+		return nil
+	case breakerHalfOpen:
+		if d.trialActive {
+			return &CircuitOpenError{Dependency: d.Name, RetryAfter: time.Second}
+		}
+		d.trialActive = true
+	}
+
+	return nil
+}
+
+func (d *Dependency) succeeded() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.state = breakerClosed
+	d.failures = 0
+	d.trialActive = false
+}
+
+// abandoned ends a trial call the caller gave up on without counting it, so the next
+// call becomes the trial
+func (d *Dependency) abandoned() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.trialActive = false
+}
+
+func (d *Dependency) failed(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures++
+	d.trialActive = false
+	d.lastError = failureKind(err)
+	d.lastErrorAt = time.Now()
+
+	if d.state == breakerHalfOpen || d.failures >= d.Threshold {
+		d.state = breakerOpen
+		d.openedAt = time.Now()
+	}
+}
+
+// guardedConn sends the calls of a generated gRPC client through a Dependency. The
+// methods in idempotent are retried, as are calls made with an idempotency key.
+type guardedConn struct {
+	conn       grpc.ClientConnInterface
+	dep        *Dependency
+	idempotent map[string]bool
+}
+
+// guard wraps conn so the calls made through it are guarded by d
+func (d *Dependency) guard(conn grpc.ClientConnInterface, idempotent map[string]bool) grpc.ClientConnInterface {
+	return &guardedConn{conn: conn, dep: d, idempotent: idempotent}
+}
+
+func (g *guardedConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	idempotent := g.idempotent[method]
+	if keyed, ok := args.(interface{ GetIdempotencyKey() string }); ok && keyed.GetIdempotencyKey() != "" {
+		idempotent = true
+	}
+
+	return g.dep.Call(ctx, idempotent, func(ctx context.Context) error {
+		return g.conn.Invoke(ctx, method, args, reply, opts...)
+	})
+}
+
+func (g *guardedConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return g.conn.NewStream(ctx, desc, method, opts...)
+}
+
+// callHTTP sends the request build makes through d. The body is read within the
+// attempt, so the response can be used after its deadline, and a 5xx response counts
+// as a failure.
+func (d *Dependency) callHTTP(ctx context.Context, client *http.Client, idempotent bool, build func(context.Context) (*http.Request, error)) (*http.Response, error) {
+	var response *http.Response
+
+	err := d.Call(ctx, idempotent, func(ctx context.Context) error {
+		request, err := build(ctx)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return &serverError{Dependency: d.Name, Status: resp.Status}
+		}
+
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		response = resp
+		return nil
+	})
+
+	return response, err
+}
+
+// serverError is a 5xx response of an HTTP dependency
+type serverError struct {
+	Dependency string
+	Status     string
+}
+
+func (e *serverError) Error() string {
+	return fmt.Sprintf("%s answered %s", e.Dependency, e.Status)
+}
+
+// failureKind sums a failure up for the diagnostics endpoint, which is public, so the
+// error itself, with its addresses and messages, isn't shown there
+func failureKind(err error) string {
+	var serverErr *serverError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), status.Code(err) == codes.DeadlineExceeded:
+		return "timeout"
+	case errors.As(err, &serverErr):
+		return "server error"
+	default:
+		return "unavailable"
+	}
+}
+
+// unhealthyRPC reports whether a gRPC error means the service could not be reached or
+// did not answer in time
+func unhealthyRPC(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// unhealthyHTTP counts every error of an HTTP attempt but the caller giving up, as
+// callHTTP only returns one for transport failures, timeouts and 5xx responses
+func unhealthyHTTP(err error) bool {
+	return !errors.Is(err, context.Canceled)
+}
+
+// unavailableJSON writes a 503 with Retry-After if err is a CircuitOpenError, and
+// reports whether it did
+func (app *Config) unavailableJSON(w http.ResponseWriter, err error) bool {
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(openErr.RetryAfter)))
+	app.errorJSON(w, openErr, http.StatusServiceUnavailable)
+	return true
+}
+
+// upstreamStatus is the status of a failed call to a downstream service that the
+// client can't be blamed for: 504 when the service didn't answer in time, 502 when it
+// couldn't be reached or answered with an error, and 500 for anything else
+func upstreamStatus(err error) int {
+	var netErr net.Error
+	var serverErr *serverError
+	var urlErr *url.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), status.Code(err) == codes.DeadlineExceeded,
+		errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout
+	case status.Code(err) == codes.Unavailable, errors.As(err, &serverErr), errors.As(err, &urlErr):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// Diagnostics shows the state of the circuit breakers of the downstream services
+func (app *Config) Diagnostics(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]DependencyStatus, 0, len(app.Dependencies))
+	for _, d := range app.Dependencies {
+		statuses = append(statuses, d.Status())
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = "Dependencies"
+	payload.Data = statuses
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// retryDelay is the jittered wait before retry number n
+func retryDelay(n int) time.Duration {
+	ceiling := retryBaseDelay << (n - 1)
+	if ceiling > retryMaxDelay {
+		ceiling = retryMaxDelay
+	}
+
+	return time.Duration(rand.Int64N(int64(ceiling))) + 1
+}
+
+// sleepContext waits for d, and reports false if ctx ended first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds, for the Retry-After header
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func testDependency() *Dependency {
+	return &Dependency{
+		Name:      "test-svc",
+		Timeout:   time.Second,
+		Threshold: 2,
+		Cooldown:  time.Minute,
+		unhealthy: unhealthyHTTP,
+		state:     breakerClosed,
+	}
+}
+
+var errDown = errors.New("connection refused")
+
+func TestCallCanceledIsNeutral(t *testing.T) {
+	d := testDependency()
+
+	_ = d.Call(context.Background(), false, func(context.Context) error { return errDown })
+	if s := d.Status(); s.ConsecutiveFailures != 1 || s.State != breakerClosed {
+		t.Fatalf("after a failure: %d failures, %s; want 1, %s", s.ConsecutiveFailures, s.State, breakerClosed)
+	}
+
+	// a caller giving up neither resets the failures nor adds to them
+	ctx, cancel := context.WithCancel(context.Background())
+	err := d.Call(ctx, true, func(context.Context) error {
+		cancel()
+		return context.Canceled
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Call = %v, want %v", err, context.Canceled)
+	}
+	if s := d.Status(); s.ConsecutiveFailures != 1 || s.State != breakerClosed {
+		t.Fatalf("after a canceled call: %d failures, %s; want 1, %s", s.ConsecutiveFailures, s.State, breakerClosed)
+	}
+}
+
+func TestCallCanceledTrialFreesTheTrial(t *testing.T) {
+	d := testDependency()
+
+	for i := 0; i < d.Threshold; i++ {
+		_ = d.Call(context.Background(), false, func(context.Context) error { return errDown })
+	}
+	if s := d.Status(); s.State != breakerOpen {
+		t.Fatalf("state = %s, want %s", s.State, breakerOpen)
+	}
+
+	// end the cooldown
+	d.mu.Lock()
+	d.openedAt = time.Now().Add(-2 * d.Cooldown)
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = d.Call(ctx, false, func(context.Context) error {
+		cancel()
+		return context.Canceled
+	})
+	if s := d.Status(); s.State != breakerHalfOpen || s.ConsecutiveFailures != d.Threshold {
+		t.Fatalf("after a canceled trial: %s, %d failures; want %s, %d", s.State, s.ConsecutiveFailures, breakerHalfOpen, d.Threshold)
+	}
+
+	// the next call is let through as the trial, and closes the breaker
+	if err := d.Call(context.Background(), false, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+	if s := d.Status(); s.State != breakerClosed || s.ConsecutiveFailures != 0 {
+		t.Fatalf("after the trial: %s, %d failures; want %s, 0", s.State, s.ConsecutiveFailures, breakerClosed)
+	}
+}
+
+func TestStatusHidesErrors(t *testing.T) {
+	d := testDependency()
+	d.Threshold = 10
+
+	_ = d.Call(context.Background(), false, func(context.Context) error {
+		return errors.New("dial tcp 10.0.3.7:8181: connection refused")
+	})
+	if got := d.Status().LastError; got != "unavailable" {
+		t.Errorf("last error = %q, want %q", got, "unavailable")
+	}
+
+	_ = d.Call(context.Background(), false, func(context.Context) error {
+		return &serverError{Dependency: d.Name, Status: "500 Internal Server Error"}
+	})
+	if got := d.Status().LastError; got != "server error" {
+		t.Errorf("last error = %q, want %q", got, "server error")
+	}
+
+	_ = d.Call(context.Background(), false, func(ctx context.Context) error {
+		return context.DeadlineExceeded
+	})
+	if got := d.Status().LastError; got != "timeout" {
+		t.Errorf("last error = %q, want %q", got, "timeout")
+	}
+}
+
+func TestUpstreamStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"rpc deadline", status.Error(codes.DeadlineExceeded, "deadline exceeded"), http.StatusGatewayTimeout},
+		{"rpc unavailable", status.Error(codes.Unavailable, "connection refused"), http.StatusBadGateway},
+		{"wrapped rpc unavailable", fmt.Errorf("exporting reservations: %w", status.Error(codes.Unavailable, "down")), http.StatusBadGateway},
+		{"transport", &url.Error{Op: "Post", URL: "https://auth-svc:8181/auth", Err: errDown}, http.StatusBadGateway},
+		{"server error", &serverError{Dependency: "auth-svc", Status: "500 Internal Server Error"}, http.StatusBadGateway},
+		{"rpc internal", status.Error(codes.Internal, "database error"), http.StatusInternalServerError},
+		{"other", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := upstreamStatus(tt.err); got != tt.want {
+			t.Errorf("%s: upstreamStatus = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The /v1 routes are the REST face of the same actions the /handle envelope takes:
+// each route builds the envelope's request for its action and hands it to the shared
+// handlers, so both stay in step. Routes differ only in their status codes.
+
+type restContextKey struct{}
+
+// restAPI marks requests that came in through the REST routes
+func restAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), restContextKey{}, true)))
+	})
+}
+
+// unauthorizedStatus is the status for a missing or invalid access token or wrong
+// credentials: 401 on the REST routes, 400 on the envelope, which always answered so
+func unauthorizedStatus(r *http.Request) int {
+	if rest, _ := r.Context().Value(restContextKey{}).(bool); rest {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
+
+// restWriter replaces the success status the shared handlers write, 200 or 202 as
+// the envelope always had it, with the one the REST route calls for, unless the
+// handler asked for its own with keepStatus
+type restWriter struct {
+	http.ResponseWriter
+	success int
+	keep    bool
+}
+
+func (w *restWriter) WriteHeader(status int) {
+	if status >= 200 && status < 300 && !w.keep {
+		status = w.success
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// keepStatus has a REST route answer with the status the handler writes rather than
+// the route's success status, for the successes that are not the route's usual one:
+// a login that needs a second factor, or a create replayed from its idempotency key.
+// The envelope is not affected.
+func keepStatus(w http.ResponseWriter) {
+	if rw, ok := w.(*restWriter); ok {
+		rw.keep = true
+	}
+}
+
+// restRoute is one REST route. The table of routes both mounts them and documents
+// them in the OpenAPI document, so the two can't disagree.
+type restRoute struct {
+	Method    string
+	Pattern   string // under /v1
+	Summary   string
+	Auth      bool     // needs an access token
+	Body      any      // a value of the request body's type, nil if there is none
+	Query     []string // names of the query parameters
+	Keyed     bool     // takes an Idempotency-Key header; a replay answers 200
+	Challenge bool     // may answer 202 with a two-factor challenge
+	Status    int      // success status
+	Reply     any      // a value of the type of the response's data, nil if there is none
+	Handler   http.HandlerFunc
+}
+
+// restRoutes are the routes of the REST API
+func (app *Config) restRoutes() []restRoute {
+	return []restRoute{
+		{Method: http.MethodPost, Pattern: "/auth/signup", Summary: "Create an account",
+			Body: AuthPayload{}, Status: http.StatusCreated, Handler: app.authRoute("signup")},
+		{Method: http.MethodPost, Pattern: "/auth/login", Summary: "Log in with email and password",
+			Body: AuthPayload{}, Challenge: true, Status: http.StatusOK, Reply: TokenPair{}, Handler: app.authRoute("login")},
+		{Method: http.MethodPost, Pattern: "/auth/refresh", Summary: "Exchange a refresh token for new tokens",
+			Body: AuthPayload{}, Status: http.StatusOK, Reply: TokenPair{}, Handler: app.authRoute("refresh")},
+		{Method: http.MethodPost, Pattern: "/auth/logout", Summary: "Revoke a refresh token",
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("logout")},
+		{Method: http.MethodPost, Pattern: "/auth/verify-email", Summary: "Verify an email address with the emailed token",
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("verify_email")},
+		{Method: http.MethodPost, Pattern: "/auth/verify-email/resend", Summary: "Send a new verification email",
+			Body: AuthPayload{}, Status: http.StatusAccepted, Handler: app.authRoute("resend_verification")},
+		{Method: http.MethodPost, Pattern: "/auth/password-reset", Summary: "Email a password reset link",
+			Body: AuthPayload{}, Status: http.StatusAccepted, Handler: app.authRoute("request_password_reset")},
+		{Method: http.MethodPost, Pattern: "/auth/password-reset/confirm", Summary: "Set a new password with the emailed token",
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("reset_password")},
+		{Method: http.MethodPost, Pattern: "/auth/2fa/verify", Summary: "Finish a login with a two-factor code",
+			Body: AuthPayload{}, Status: http.StatusOK, Reply: TokenPair{}, Handler: app.authRoute("2fa_verify")},
+		{Method: http.MethodPost, Pattern: "/auth/oidc/{provider}", Summary: "Start a login with an identity provider",
+			Status: http.StatusOK, Reply: AuthorizationRedirect{}, Handler: app.authRoute("oidc_start")},
+		{Method: http.MethodPost, Pattern: "/auth/oidc/callback", Summary: "Finish a login with an identity provider",
+			Body: AuthPayload{}, Challenge: true, Status: http.StatusOK, Reply: TokenPair{}, Handler: app.authRoute("oidc_callback")},
+
+		{Method: http.MethodGet, Pattern: "/me", Summary: "Get the profile of the logged in user", Auth: true,
+			Status: http.StatusOK, Reply: Profile{}, Handler: app.authRoute("get_profile")},
+		{Method: http.MethodPatch, Pattern: "/me", Summary: "Change the full name", Auth: true,
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("update_profile")},
+		{Method: http.MethodDelete, Pattern: "/me", Summary: "Delete the account", Auth: true,
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("delete_account")},
+		{Method: http.MethodGet, Pattern: "/me/export", Summary: "Export the user's personal data", Auth: true,
+			Status: http.StatusOK, Reply: DataExport{}, Handler: app.authRoute("export_data")},
+		{Method: http.MethodPost, Pattern: "/me/email", Summary: "Change the email address", Auth: true,
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("change_email")},
+		{Method: http.MethodPost, Pattern: "/me/password", Summary: "Change the password", Auth: true,
+			Body: AuthPayload{}, Status: http.StatusOK, Reply: TokenPair{}, Handler: app.authRoute("change_password")},
+		{Method: http.MethodPost, Pattern: "/me/2fa", Summary: "Start enrolling in two-factor authentication", Auth: true,
+			Status: http.StatusOK, Reply: TwoFactorEnrollment{}, Handler: app.authRoute("2fa_enroll")},
+		{Method: http.MethodPost, Pattern: "/me/2fa/confirm", Summary: "Enable two-factor authentication with a first code", Auth: true,
+			Body: AuthPayload{}, Status: http.StatusOK, Reply: TwoFactorEnrollment{}, Handler: app.authRoute("2fa_confirm")},
+		{Method: http.MethodDelete, Pattern: "/me/2fa", Summary: "Disable two-factor authentication", Auth: true,
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("2fa_disable")},
+
+		{Method: http.MethodPut, Pattern: "/users/{email}/role", Summary: "Change a user's role", Auth: true,
+			Body: AuthPayload{}, Status: http.StatusOK, Handler: app.authRoute("set_role")},
+
+		{Method: http.MethodGet, Pattern: "/reservations", Summary: "List the user's reservations, or a restaurant's", Auth: true,
+			Query: []string{"restaurantID", "page", "pageSize"}, Status: http.StatusOK, Reply: ReservationList{}, Handler: app.v1ListReservations},
+		{Method: http.MethodPost, Pattern: "/reservations", Summary: "Make a reservation", Auth: true,
+			Body: ReservationData{}, Keyed: true, Status: http.StatusCreated, Handler: app.reservationRoute("add")},
+		{Method: http.MethodGet, Pattern: "/reservations/{id}", Summary: "Get a reservation", Auth: true,
+			Status: http.StatusOK, Reply: ReservationData{}, Handler: app.reservationRoute("get")},
+		{Method: http.MethodPatch, Pattern: "/reservations/{id}", Summary: "Change a reservation", Auth: true,
+			Body: ReservationData{}, Status: http.StatusOK, Handler: app.reservationRoute("update")},
+		{Method: http.MethodDelete, Pattern: "/reservations/{id}", Summary: "Cancel a reservation", Auth: true,
+			Status: http.StatusOK, Handler: app.reservationRoute("cancel")},
+
+		{Method: http.MethodGet, Pattern: "/restaurants", Summary: "List restaurants", Auth: true,
+			Query: []string{"page", "pageSize"}, Status: http.StatusOK, Reply: RestaurantList{}, Handler: app.v1ListRestaurants},
+		{Method: http.MethodPost, Pattern: "/restaurants", Summary: "Add a restaurant", Auth: true,
+			Body: RestaurantData{}, Status: http.StatusCreated, Handler: app.v1CreateRestaurant},
+		{Method: http.MethodGet, Pattern: "/restaurants/{id}/availability", Summary: "Get a restaurant's free slots on a date", Auth: true,
+			Query: []string{"date", "partySize"}, Status: http.StatusOK, Reply: Availability{}, Handler: app.v1Availability},
+		{Method: http.MethodPut, Pattern: "/restaurants/{id}/staff/{userID}", Summary: "Add a user to a restaurant's staff", Auth: true,
+			Status: http.StatusOK, Handler: app.staffRoute("assign_staff")},
+		{Method: http.MethodDelete, Pattern: "/restaurants/{id}/staff/{userID}", Summary: "Remove a user from a restaurant's staff", Auth: true,
+			Status: http.StatusOK, Handler: app.staffRoute("remove_staff")},
+	}
+}
+
+// v1Routes mounts the REST API
+func (app *Config) v1Routes(mux chi.Router) {
+	mux.Use(restAPI)
+
+	for _, route := range app.restRoutes() {
+		mux.Method(route.Method, route.Pattern, successStatus(route.Status, route.Handler))
+	}
+}
+
+// successStatus has next answer with status when it succeeds
+func successStatus(status int, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(&restWriter{ResponseWriter: w, success: status}, r)
+	}
+}
+
+// authRoute serves an auth action. The body, if any, is the action's authData; the
+// provider and the email set_role acts on come from the path.
+func (app *Config) authRoute(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var a AuthPayload
+		if !app.readRESTBody(w, r, &a) {
+			return
+		}
+
+		if provider := chi.URLParam(r, "provider"); provider != "" {
+			a.Provider = provider
+		}
+		if email := chi.URLParam(r, "email"); email != "" {
+			a.Email = email
+		}
+
+		app.authenticate(w, r, AuthRequest{Action: action, AuthData: a})
+	}
+}
+
+// reservationRoute serves a reservation action. The body, if any, is the reservation
+// and the id comes from the path.
+func (app *Config) reservationRoute(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rd ReservationData
+		if !app.readRESTBody(w, r, &rd) {
+			return
+		}
+
+		rd.ReservationID = chi.URLParam(r, "id")
+
+		app.reservation(w, r, ReservationRequest{Action: action, ReservationData: rd})
+	}
+}
+
+// v1ListReservations lists the caller's reservations, or with ?restaurantID= those
+// of a restaurant they manage
+func (app *Config) v1ListReservations(w http.ResponseWriter, r *http.Request) {
+	errs := fieldErrors{}
+	req := ReservationRequest{
+		Action:          "list",
+		ReservationData: ReservationData{RestaurantID: r.URL.Query().Get("restaurantID")},
+		Page:            queryInt(r, "page", errs),
+		PageSize:        queryInt(r, "pageSize", errs),
+	}
+	if len(errs) > 0 {
+		app.validationErrorJSON(w, "invalid query", errs)
+		return
+	}
+
+	app.reservation(w, r, req)
+}
+
+// v1Availability returns a restaurant's free slots on ?date= for ?partySize= guests
+func (app *Config) v1Availability(w http.ResponseWriter, r *http.Request) {
+	errs := fieldErrors{}
+	req := ReservationRequest{
+		Action: "availability",
+		ReservationData: ReservationData{
+			RestaurantID: chi.URLParam(r, "id"),
+			Count:        queryInt(r, "partySize", errs),
+		},
+		Date: r.URL.Query().Get("date"),
+	}
+	if len(errs) > 0 {
+		app.validationErrorJSON(w, "invalid query", errs)
+		return
+	}
+
+	app.reservation(w, r, req)
+}
+
+func (app *Config) v1ListRestaurants(w http.ResponseWriter, r *http.Request) {
+	errs := fieldErrors{}
+	req := RestaurantRequest{
+		Action:   "list",
+		Page:     queryInt(r, "page", errs),
+		PageSize: queryInt(r, "pageSize", errs),
+	}
+	if len(errs) > 0 {
+		app.validationErrorJSON(w, "invalid query", errs)
+		return
+	}
+
+	app.restaurant(w, r, req)
+}
+
+func (app *Config) v1CreateRestaurant(w http.ResponseWriter, r *http.Request) {
+	var rd RestaurantData
+	if !app.readRESTBody(w, r, &rd) {
+		return
+	}
+
+	app.restaurant(w, r, RestaurantRequest{Action: "add", RestaurantData: rd})
+}
+
+// staffRoute assigns the user in the path to the restaurant's staff, or removes them
+func (app *Config) staffRoute(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := RestaurantRequest{
+			Action:         action,
+			RestaurantData: RestaurantData{RestaurantID: chi.URLParam(r, "id")},
+			StaffUserID:    chi.URLParam(r, "userID"),
+		}
+
+		app.restaurant(w, r, req)
+	}
+}
+
+// readRESTBody decodes the JSON body into dst, writing an error response if it is
+// invalid. An empty body leaves dst as it is.
+func (app *Config) readRESTBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+	err := app.readJSON(w, r, dst)
+	if err == nil || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	if errs := decodeFieldErrors(err); len(errs) > 0 {
+		app.validationErrorJSON(w, "invalid request", errs)
+		return false
+	}
+
+	app.errorJSON(w, err)
+	return false
+}
+
+// queryInt returns the integer query parameter name, or 0 if it is missing; an
+// invalid value is recorded in errs
+func queryInt(r *http.Request, name string, errs fieldErrors) int {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		errs[name] = "must be a number"
+	}
+
+	return n
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSuccessStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{
+			name:    "envelope success",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) },
+			want:    http.StatusCreated,
+		},
+		{
+			name:    "error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusConflict) },
+			want:    http.StatusConflict,
+		},
+		{
+			name: "kept status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				keepStatus(w)
+				w.WriteHeader(http.StatusOK)
+			},
+			want: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		successStatus(http.StatusCreated, tt.handler)(w, httptest.NewRequest(http.MethodPost, "/v1/reservations", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	reservationpb "proto/reservation"
+)
+
+type RestaurantRequest struct {
+	Action         string         `json:"action"`
+	RestaurantData RestaurantData `json:"restaurantData"`
+	Page           int            `json:"page,omitempty"`
+	PageSize       int            `json:"pageSize,omitempty"`
+
+	// StaffUserID is the user assign_staff and remove_staff act on
+	StaffUserID string `json:"staffUserID,omitempty"`
+}
+
+type RestaurantData struct {
+	RestaurantID  string         `json:"id,omitempty"`
+	Name          string         `json:"name"`
+	Address       string         `json:"address,omitempty"`
+	Timezone      string         `json:"timezone,omitempty"`
+	Capacity      int            `json:"capacity"`
+	Tables        []TableData    `json:"tables"`
+	OpeningHours  []OpeningHours `json:"openingHours"`
+	BlackoutDates []BlackoutDate `json:"blackoutDates"`
+	CreatedAt     time.Time      `json:"createdAt,omitempty"`
+}
+
+type TableData struct {
+	TableID string `json:"id,omitempty"`
+	Label   string `json:"label"`
+	Seats   int    `json:"seats"`
+}
+
+type OpeningHours struct {
+	Weekday int    `json:"weekday"`
+	Opens   string `json:"opens"`
+	Closes  string `json:"closes"`
+}
+
+type BlackoutDate struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// restaurantActionPermissions is the permission each restaurant action needs
+var restaurantActionPermissions = map[string]permission{
+	"add":          permWriteRestaurants,
+	"list":         permReadRestaurants,
+	"assign_staff": permManageStaff,
+	"remove_staff": permManageStaff,
+}
+
+// RestaurantList is the reply of the reservation service's ListRestaurants
+type RestaurantList struct {
+	Restaurants []RestaurantData `json:"restaurants"`
+	Page        int              `json:"page"`
+	PageSize    int              `json:"pageSize"`
+	Total       int              `json:"total"`
+}
+
+func (app *Config) restaurant(w http.ResponseWriter, r *http.Request, restaurantReq RestaurantRequest) {
+	caller, ok := app.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	needed, ok := restaurantActionPermissions[restaurantReq.Action]
+	if !ok {
+		app.errorJSON(w, errors.New("unknown action"))
+		return
+	}
+	if !app.authorize(w, caller, needed) {
+		return
+	}
+
+	switch restaurantReq.Action {
+	case "add":
+		app.createRestaurant(w, r, caller, restaurantReq.RestaurantData)
+	case "list":
+		app.listRestaurants(w, r, restaurantReq)
+	case "assign_staff":
+		app.changeStaff(w, r, caller, restaurantReq, false)
+	case "remove_staff":
+		app.changeStaff(w, r, caller, restaurantReq, true)
+	}
+}
+
+func (app *Config) createRestaurant(w http.ResponseWriter, r *http.Request, caller Caller, rd RestaurantData) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.CreateRestaurant(ctx, &reservationpb.RestaurantRequest{
+		Caller:     caller.proto(),
+		Restaurant: restaurantToProto(rd),
+	})
+	if err != nil {
+		log.Println("Error creating restaurant via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error creating restaurant")
+		return
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = "Reservation Service!: Restaurant created successfully"
+	payload.Data = map[string]string{"id": result.GetId()}
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) listRestaurants(w http.ResponseWriter, r *http.Request, restaurantReq RestaurantRequest) {
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.ListRestaurants(ctx, &reservationpb.RestaurantRequest{
+		Page:     int32(restaurantReq.Page),
+		PageSize: int32(restaurantReq.PageSize),
+	})
+	if err != nil {
+		log.Println("Error listing restaurants via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error listing restaurants")
+		return
+	}
+
+	restaurants := make([]RestaurantData, 0, len(result.GetRestaurants()))
+	for _, r := range result.GetRestaurants() {
+		restaurants = append(restaurants, restaurantFromProto(r))
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = "Reservation Service!: Restaurants fetched successfully"
+	payload.Data = RestaurantList{
+		Restaurants: restaurants,
+		Page:        int(result.GetPage()),
+		PageSize:    int(result.GetPageSize()),
+		Total:       int(result.GetTotal()),
+	}
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// changeStaff assigns restaurantReq.StaffUserID to the restaurant's staff, or removes
+// them from it
+func (app *Config) changeStaff(w http.ResponseWriter, r *http.Request, caller Caller, restaurantReq RestaurantRequest, remove bool) {
+	errs := fieldErrors{}
+	if restaurantReq.RestaurantData.RestaurantID == "" {
+		errs["restaurantData.id"] = "is required"
+	}
+	if restaurantReq.StaffUserID == "" {
+		errs["staffUserID"] = "is required"
+	}
+	if len(errs) > 0 {
+		app.validationErrorJSON(w, "invalid staff assignment", errs)
+		return
+	}
+
+	ctx := rpcContext(r)
+
+	result, err := reservationClient.AssignStaff(ctx, &reservationpb.StaffRequest{
+		Caller:       caller.proto(),
+		RestaurantId: restaurantReq.RestaurantData.RestaurantID,
+		UserId:       restaurantReq.StaffUserID,
+		Remove:       remove,
+	})
+	if err != nil {
+		log.Println("Error changing restaurant staff via rpc from broker: ", err)
+		app.reservationErrorJSON(w, err, "error changing restaurant staff")
+		return
+	}
+
+	var payload jsonResponse
+	payload.Error = false
+	payload.Message = fmt.Sprintf("Reservation Service!: %s", result.GetMessage())
+	app.writeJSON(w, http.StatusOK, payload)
+}
@@ -3,32 +3,91 @@ package main
 import (
 	"net/http"
 
+	"proto/requestid"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// exposedHeaders are the response headers browsers let clients read
+var exposedHeaders = []string{
+	"Link",
+	"Retry-After",
+	"Idempotent-Replayed",
+	"X-Request-Id",
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+}
+
 // routes method to configure the app's routes
 func (app *Config) routes() http.Handler {
 	// Create a new router instance
 	mux := chi.NewRouter()
 
+	// Trace every request, continuing the caller's trace if it sent a traceparent
+	mux.Use(otelhttp.NewMiddleware("broker-svc"), nameSpanAfterRoute)
+
+	// Give every request an ID, passed on to the services and stored with their logs
+	mux.Use(withRequestID)
+
 	// Apply CORS middleware
 	mux.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"}, // Allow all origins
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Request-Id"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Request-Id", "Idempotency-Key"},
 		AllowCredentials: true,
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   exposedHeaders,
 		MaxAge:           300, // Cache the preflight response for 5 minutes
 	}))
 
 	// Apply heartbeat middleware for health check
 	mux.Use(middleware.Heartbeat("/health"))
 
+	// Limit the requests of each client and user
+	mux.Use(app.rateLimit)
+
+	// Validate requests against the OpenAPI document
+	mux.Use(app.validateRequests)
+
 	// Define routes and handlers
 	mux.Get("/", app.Broker)
 	mux.Post("/handle", app.HandleSubmission)
+	mux.Get("/.well-known/jwks.json", app.JWKS)
+	mux.Get("/openapi.json", app.OpenAPIDocument)
+	mux.Get("/diagnostics/dependencies", app.Diagnostics)
+	mux.Route("/v1", app.v1Routes)
 
 	return mux
 }
+
+// nameSpanAfterRoute names the request's span after the route it matched, such as
+// "GET /v1/reservations/{id}", which is only known once the router is done
+func nameSpanAfterRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if pattern := chi.RouteContext(r.Context()).RoutePattern(); pattern != "" {
+			trace.SpanFromContext(r.Context()).SetName(r.Method + " " + pattern)
+		}
+	})
+}
+
+// withRequestID keeps the client's X-Request-Id if it is valid, or assigns a new one.
+// The ID is echoed in the response, put in the request's context for the calls made
+// for it, and recorded on its span.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", id))
+
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// rpcTLS is used to call the reservation and logger services' RPC ports and auth-svc's
+// HTTP port, which only accept clients with a certificate from the services' CA (see
+// project/gen-certs.sh)
+var rpcTLS *tls.Config
+
+// loadRPCTLS reads the certificate, key and CA named by RPC_TLS_CERT, RPC_TLS_KEY and
+// RPC_TLS_CA
+func loadRPCTLS() (*tls.Config, error) {
+	certFile, keyFile, caFile := os.Getenv("RPC_TLS_CERT"), os.Getenv("RPC_TLS_KEY"), os.Getenv("RPC_TLS_CA")
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, errors.New("RPC_TLS_CERT, RPC_TLS_KEY and RPC_TLS_CA are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
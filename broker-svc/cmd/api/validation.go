@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// limits enforced on reservation requests before they reach the reservation service
+const (
+	minPartySize     = 1
+	maxPartySize     = 20
+	maxRemarksLength = 500
+)
+
+// fieldErrors maps a JSON field name to what is wrong with it
+type fieldErrors map[string]string
+
+// validateReservationRequest checks the fields used by the requested action. For
+// "update", zero fields mean "leave unchanged" and are not validated.
+func validateReservationRequest(req ReservationRequest, now time.Time) fieldErrors {
+	errs := fieldErrors{}
+	rd := req.ReservationData
+
+	switch req.Action {
+	case "add":
+		if rd.RestaurantID == "" {
+			errs["restaurantID"] = "restaurant is required"
+		}
+		validatePartySize(errs, rd.Count)
+		if rd.ReservationTime.IsZero() {
+			errs["reservationTime"] = "reservation time is required"
+		} else {
+			validateReservationTime(errs, rd.ReservationTime, now)
+		}
+		validateRemarks(errs, rd.Remarks)
+	case "update":
+		if rd.ReservationID == "" {
+			errs["id"] = "reservation id is required"
+		}
+		if rd.Count != 0 {
+			validatePartySize(errs, rd.Count)
+		}
+		if !rd.ReservationTime.IsZero() {
+			validateReservationTime(errs, rd.ReservationTime, now)
+		}
+		validateRemarks(errs, rd.Remarks)
+	case "get", "cancel":
+		if rd.ReservationID == "" {
+			errs["id"] = "reservation id is required"
+		}
+	case "availability":
+		if rd.RestaurantID == "" {
+			errs["restaurantID"] = "restaurant is required"
+		}
+		validatePartySize(errs, rd.Count)
+		if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+			errs["date"] = "date must be formatted as YYYY-MM-DD"
+		}
+	}
+
+	return errs
+}
+
+func validatePartySize(errs fieldErrors, count int) {
+	if count < minPartySize || count > maxPartySize {
+		errs["count"] = fmt.Sprintf("party size must be between %d and %d", minPartySize, maxPartySize)
+	}
+}
+
+func validateReservationTime(errs fieldErrors, at time.Time, now time.Time) {
+	if !at.After(now) {
+		errs["reservationTime"] = "reservation time must be in the future"
+	}
+}
+
+func validateRemarks(errs fieldErrors, remarks string) {
+	if utf8.RuneCountInString(remarks) > maxRemarksLength {
+		errs["remarks"] = fmt.Sprintf("remarks must be at most %d characters", maxRemarksLength)
+	}
+}
+
+// decodeFieldErrors turns JSON type mismatches, such as "two" for a party size, into
+// field errors. It returns nil for any other decoding error.
+func decodeFieldErrors(err error) fieldErrors {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		field := typeErr.Field[strings.LastIndex(typeErr.Field, ".")+1:]
+		return fieldErrors{field: fmt.Sprintf("must be a %s", jsonTypeName(typeErr.Type.Kind().String()))}
+	}
+
+	var timeErr *time.ParseError
+	if errors.As(err, &timeErr) {
+		return fieldErrors{"reservationTime": "must be an RFC 3339 time with a timezone, e.g. 2025-05-01T19:30:00+02:00"}
+	}
+
+	return nil
+}
+
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "struct", kind == "map":
+		return "object"
+	case kind == "slice", kind == "array":
+		return "list"
+	case kind == "bool":
+		return "boolean"
+	default:
+		return kind
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	loggerpb "proto/logger"
+	"proto/requestid"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer serves the LoggerService contract from proto/logger on top of the
+// RPCServer methods, which also still back the gob endpoint while callers migrate
+type grpcServer struct {
+	loggerpb.UnimplementedLoggerServiceServer
+	rpc RPCServer
+}
+
+// grpcListen serves the LoggerService over mutual TLS until the listener fails, or until
+// SIGINT or SIGTERM, after which the calls in flight are finished
+func grpcListen() error {
+	log.Println("Starting Logger gRPC server on port: ", grpcPort)
+	listen, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%s", grpcPort))
+	if err != nil {
+		log.Println("Error starting gRPC server: ", err)
+		return err
+	}
+
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(rpcServerTLS)),
+		grpc.ConnectionTimeout(rpcHandshakeTimeout),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(requestid.UnaryServerInterceptor()),
+	)
+	loggerpb.RegisterLoggerServiceServer(srv, &grpcServer{})
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		log.Println("Shutting down Logger gRPC server")
+		srv.GracefulStop()
+	}()
+
+	return srv.Serve(listen)
+}
+
+func (s *grpcServer) Log(ctx context.Context, req *loggerpb.LogRequest) (*loggerpb.LogReply, error) {
+	payload := RPCPayload{
+		Name:      req.GetName(),
+		Data:      req.GetData(),
+		UserID:    req.GetUserId(),
+		RequestID: req.GetRequestId(),
+	}
+	// callers that don't set the field still pass the ID on in the metadata
+	if payload.RequestID == "" {
+		payload.RequestID = requestid.FromContext(ctx)
+	}
+
+	message, err := logInfo(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loggerpb.LogReply{Message: message}, nil
+}
+
+func (s *grpcServer) EntriesForUser(_ context.Context, req *loggerpb.EntriesRequest) (*loggerpb.EntriesReply, error) {
+	query := EntriesQuery{
+		UserID:     req.GetUserId(),
+		NamePrefix: req.GetNamePrefix(),
+	}
+
+	var resp []LogEntry
+	if err := s.rpc.EntriesForUser(query, &resp); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*loggerpb.LogEntry, 0, len(resp))
+	for _, e := range resp {
+		entries = append(entries, &loggerpb.LogEntry{
+			Id:        e.ID,
+			Name:      e.Name,
+			Data:      e.Data,
+			UserId:    e.UserID,
+			RequestId: e.RequestID,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+			UpdatedAt: timestamppb.New(e.UpdatedAt),
+		})
+	}
+
+	return &loggerpb.EntriesReply{Entries: entries}, nil
+}
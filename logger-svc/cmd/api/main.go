@@ -10,12 +10,17 @@ import (
 	"strconv"
 	"time"
 
+	"proto/telemetry"
+
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// port constants
+// port constants. The gob endpoint on rpcPort is only started with
+// GOB_RPC_ENABLED=true, for callers that have not moved to gRPC yet.
 const (
+	grpcPort = "50001"
 	rpcPort  = "5001"
 	mongoURL = "mongodb://mongo:27017"
 )
@@ -25,14 +30,31 @@ var client *mongo.Client
 var maxAcceptError int
 
 func main() {
+	shutdownTracing, err := telemetry.Setup(context.Background(), "logger-svc")
+	if err != nil {
+		log.Fatal("Error setting up tracing: ", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Println("Error flushing traces: ", err)
+		}
+	}()
+
 	maxAcceptErrorStr := os.Getenv("MAX_ACCEPT_ERROR")
 
-	var err error
 	maxAcceptError, err = strconv.Atoi(maxAcceptErrorStr)
 	if err != nil {
 		log.Fatalf("Error converting MAX_ACCEPT_ERROR to integer: %v", err)
 	}
 
+	rpcServerTLS, err = loadRPCTLS(os.Getenv("RPC_ALLOWED_CLIENTS"))
+	if err != nil {
+		log.Fatal("Error loading RPC certificates: ", err)
+	}
+
 	mongoClient, err := connectToMongo()
 	if err != nil {
 		log.Fatal("Error connecting to MongoDB: ", err)
@@ -43,18 +65,36 @@ func main() {
 	defer cancel()
 
 	defer func() {
+		// the startup context has expired by the time the server shuts down
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
 		if err = client.Disconnect(ctx); err != nil {
 			log.Fatal("Error disconnecting from MongoDB: ", err)
 		}
 	}()
 
-	err = rpc.Register(new(RPCServer))
-	if err != nil {
-		log.Panic("Error registering Logger RPC server: ", err)
+	if err = createIndexes(ctx); err != nil {
+		log.Println("Error creating log indexes: ", err)
+	}
+
+	if os.Getenv("GOB_RPC_ENABLED") == "true" {
+		err = rpc.Register(new(RPCServer))
+		if err != nil {
+			log.Panic("Error registering Logger RPC server: ", err)
+		}
+
+		go func() {
+			if err := rpcListen(); err != nil {
+				log.Panic("Logger RPC server exited with error: ", err)
+			}
+		}()
 	}
 
-	if err := rpcListen(); err != nil {
-		log.Panic("Logger RPC server exited with error: ", err)
+	// grpcListen returns once the calls in flight are done after SIGINT or SIGTERM, so
+	// MongoDB is disconnected after them
+	if err := grpcListen(); err != nil {
+		log.Panic("Logger gRPC server exited with error: ", err)
 	}
 }
 
@@ -87,7 +127,7 @@ func rpcListen() error {
 
 		// reset error count on successful accept
 		acceptFailures = 0
-		go rpc.ServeConn(conn)
+		go serveRPCConn(conn)
 	}
 }
 
@@ -104,3 +144,21 @@ func connectToMongo() (*mongo.Client, error) {
 
 	return c, err
 }
+
+// createIndexes makes sure entries can be looked up by user for data exports, and by
+// request ID for the entries of one request
+func createIndexes(ctx context.Context) error {
+	collection := client.Database("logs").Collection("logs")
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "request_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+
+	return err
+}
@@ -3,38 +3,136 @@ package main
 import (
 	"context"
 	"log"
+	"regexp"
 	"time"
+
+	"proto/requestid"
+	"proto/telemetry"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RPCServer struct{}
 
 type RPCPayload struct {
-	Name string
-	Data string
+	Name   string
+	Data   string
+	UserID string
+
+	// RequestID is the ID the broker gave the request the entry is about
+	RequestID string
+
+	// TraceContext is the caller's W3C trace context, such as its traceparent
+	TraceContext map[string]string
 }
 
 type LogEntry struct {
 	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
 	Name      string    `bson:"name" json:"name"`
 	Data      string    `bson:"data" json:"data"`
+	UserID    string    `bson:"user_id,omitempty" json:"userId,omitempty"`
+	RequestID string    `bson:"request_id,omitempty" json:"requestId,omitempty"`
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
+// EntriesQuery selects the log entries about one user, optionally only the ones
+// whose name starts with NamePrefix (such as "Auth_")
+type EntriesQuery struct {
+	UserID     string
+	NamePrefix string
+}
+
+const maxEntriesPerQuery = 10000
+
+var tracer = otel.Tracer("logger")
+
 func (r *RPCServer) LogInfoViaRPC(payload RPCPayload, resp *string) error {
+	ctx, span := tracer.Start(telemetry.Extract(context.Background(), payload.TraceContext), "RPCServer.LogInfoViaRPC",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	message, err := logInfo(ctx, payload)
+	telemetry.End(span, err)
+	if err != nil {
+		return err
+	}
+
+	*resp = message
+	return nil
+}
+
+// logInfo stores the entry of LogInfoViaRPC. ctx carries the caller's trace; the entry
+// is stored even if the caller gives up on it. A request ID that isn't valid is not
+// stored.
+func logInfo(ctx context.Context, payload RPCPayload) (string, error) {
 	collection := client.Database("logs").Collection("logs")
 
-	_, err := collection.InsertOne(context.TODO(), LogEntry{
+	if !requestid.Valid(payload.RequestID) {
+		payload.RequestID = ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "insert logs",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "mongodb"), attribute.String("db.collection.name", "logs")),
+	)
+	_, err := collection.InsertOne(ctx, LogEntry{
 		Name:      payload.Name,
 		Data:      payload.Data,
+		UserID:    payload.UserID,
+		RequestID: payload.RequestID,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	})
+	telemetry.End(span, err)
 	if err != nil {
 		log.Println("Error inserting into logs via RPC: ", err)
+		return "", err
+	}
+
+	return "Successfully logged via RPC: " + payload.Name + " - " + payload.Data, nil
+}
+
+// EntriesForUser returns the entries about a user, oldest first
+func (r *RPCServer) EntriesForUser(query EntriesQuery, resp *[]LogEntry) error {
+	if query.UserID == "" {
+		*resp = []LogEntry{}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	collection := client.Database("logs").Collection("logs")
+
+	filter := bson.M{"user_id": query.UserID}
+	if query.NamePrefix != "" {
+		filter["name"] = bson.M{"$regex": "^" + regexp.QuoteMeta(query.NamePrefix)}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetLimit(maxEntriesPerQuery)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println("Error finding logs via RPC: ", err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []LogEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		log.Println("Error reading logs via RPC: ", err)
 		return err
 	}
 
-	*resp = "Successfully logged via RPC: " + payload.Name + " - " + payload.Data
+	*resp = entries
 	return nil
 }
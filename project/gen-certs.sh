@@ -0,0 +1,34 @@
+#!/bin/sh
+# Generates a local CA and a certificate for every service, used for the mutual TLS
+# on the reservation and logger RPC ports and auth-svc's HTTP port. Each service directory gets its own
+# cert.pem, key.pem and the CA's ca.pem; the CA's key stays in the top directory.
+set -e
+
+DIR=${1:-./certs}
+DAYS=825
+SERVICES="broker-svc auth-svc reservation-svc logger-svc"
+
+mkdir -p "$DIR"
+
+if [ ! -f "$DIR/ca.key" ]; then
+	openssl req -x509 -newkey ec -pkeyopt ec_paramgen_curve:P-256 -nodes -days $DAYS \
+		-subj "/CN=booking-system-rpc-ca" -keyout "$DIR/ca.key" -out "$DIR/ca.pem"
+fi
+
+for svc in $SERVICES; do
+	mkdir -p "$DIR/$svc"
+
+	# the service name is both the host name clients dial and the name servers check
+	# against RPC_ALLOWED_CLIENTS
+	printf "subjectAltName=DNS:%s\nextendedKeyUsage=serverAuth,clientAuth\n" "$svc" > "$DIR/$svc/ext.cnf"
+
+	openssl req -newkey ec -pkeyopt ec_paramgen_curve:P-256 -nodes \
+		-subj "/CN=$svc" -keyout "$DIR/$svc/key.pem" -out "$DIR/$svc/csr.pem"
+	openssl x509 -req -in "$DIR/$svc/csr.pem" -CA "$DIR/ca.pem" -CAkey "$DIR/ca.key" -CAcreateserial \
+		-days $DAYS -extfile "$DIR/$svc/ext.cnf" -out "$DIR/$svc/cert.pem"
+
+	cp "$DIR/ca.pem" "$DIR/$svc/ca.pem"
+	rm "$DIR/$svc/csr.pem" "$DIR/$svc/ext.cnf"
+done
+
+echo "Certificates written to $DIR"
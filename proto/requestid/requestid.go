@@ -0,0 +1,88 @@
+// Package requestid carries the ID the broker gives every request, so the log entries
+// the services write for it can be found together. The ID travels in the X-Request-Id
+// header over HTTP, in the x-request-id metadata over gRPC, and in a field of its own
+// in payloads such as the logger's.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Header is the HTTP header of the request ID
+const Header = "X-Request-Id"
+
+// metadataKey is the gRPC metadata key of the request ID
+const metadataKey = "x-request-id"
+
+// MaxLength is the length of the longest ID accepted from a caller
+const MaxLength = 128
+
+type contextKey struct{}
+
+// New returns a random request ID
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether id may be used as a request ID: 1 to MaxLength letters,
+// digits, or any of "-_.:", so it is safe to echo and store
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewContext returns ctx carrying the request ID id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID ctx carries, or "" if it has none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// UnaryClientInterceptor sends the request ID of a call's context in its metadata
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := FromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, metadataKey, id)
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerInterceptor puts the request ID a call came with, if it is valid, in the
+// context of its handler
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if ids := md.Get(metadataKey); len(ids) > 0 && Valid(ids[0]) {
+				ctx = NewContext(ctx, ids[0])
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
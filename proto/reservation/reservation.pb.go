@@ -0,0 +1,1391 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: reservation/reservation.proto
+
+// The reservation service books tables and manages restaurants. It is called by the
+// broker on behalf of an authenticated user.
+
+package reservationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Caller is the authenticated user a call is made on behalf of. The broker fills it
+// from the verified JWT.
+type Caller struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Role          string                 `protobuf:"bytes,2,opt,name=role,proto3" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Caller) Reset() {
+	*x = Caller{}
+	mi := &file_reservation_reservation_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Caller) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Caller) ProtoMessage() {}
+
+func (x *Caller) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Caller.ProtoReflect.Descriptor instead.
+func (*Caller) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Caller) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Caller) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+// Result is the reply of the calls that only report success
+type Result struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Message string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	// replayed is set when an idempotency key matched an earlier request, whose
+	// result this is
+	Replayed      bool `protobuf:"varint,2,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_reservation_reservation_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Result) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Result) GetReplayed() bool {
+	if x != nil {
+		return x.Replayed
+	}
+	return false
+}
+
+type Reservation struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	RestaurantId    string                 `protobuf:"bytes,2,opt,name=restaurant_id,json=restaurantId,proto3" json:"restaurant_id,omitempty"`
+	UserId          string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Count           int32                  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	ReservationTime *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=reservation_time,json=reservationTime,proto3" json:"reservation_time,omitempty"`
+	Remarks         string                 `protobuf:"bytes,6,opt,name=remarks,proto3" json:"remarks,omitempty"`
+	Status          string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	CreatedAt       *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Reservation) Reset() {
+	*x = Reservation{}
+	mi := &file_reservation_reservation_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reservation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reservation) ProtoMessage() {}
+
+func (x *Reservation) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reservation.ProtoReflect.Descriptor instead.
+func (*Reservation) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Reservation) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Reservation) GetRestaurantId() string {
+	if x != nil {
+		return x.RestaurantId
+	}
+	return ""
+}
+
+func (x *Reservation) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Reservation) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Reservation) GetReservationTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReservationTime
+	}
+	return nil
+}
+
+func (x *Reservation) GetRemarks() string {
+	if x != nil {
+		return x.Remarks
+	}
+	return ""
+}
+
+func (x *Reservation) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Reservation) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type ReservationRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Caller      *Caller                `protobuf:"bytes,1,opt,name=caller,proto3" json:"caller,omitempty"`
+	Reservation *Reservation           `protobuf:"bytes,2,opt,name=reservation,proto3" json:"reservation,omitempty"`
+	Page        int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize    int32                  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// idempotency_key makes a retried CreateReservation return the result of the first
+	// request with the key instead of booking again
+	IdempotencyKey string `protobuf:"bytes,5,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ReservationRequest) Reset() {
+	*x = ReservationRequest{}
+	mi := &file_reservation_reservation_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReservationRequest) ProtoMessage() {}
+
+func (x *ReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReservationRequest.ProtoReflect.Descriptor instead.
+func (*ReservationRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ReservationRequest) GetCaller() *Caller {
+	if x != nil {
+		return x.Caller
+	}
+	return nil
+}
+
+func (x *ReservationRequest) GetReservation() *Reservation {
+	if x != nil {
+		return x.Reservation
+	}
+	return nil
+}
+
+func (x *ReservationRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ReservationRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ReservationRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+type ReservationList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reservations  []*Reservation         `protobuf:"bytes,1,rep,name=reservations,proto3" json:"reservations,omitempty"`
+	Page          int32                  `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize      int32                  `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	Total         int32                  `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReservationList) Reset() {
+	*x = ReservationList{}
+	mi := &file_reservation_reservation_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReservationList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReservationList) ProtoMessage() {}
+
+func (x *ReservationList) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReservationList.ProtoReflect.Descriptor instead.
+func (*ReservationList) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ReservationList) GetReservations() []*Reservation {
+	if x != nil {
+		return x.Reservations
+	}
+	return nil
+}
+
+func (x *ReservationList) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ReservationList) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ReservationList) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type AvailabilityRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RestaurantId string                 `protobuf:"bytes,1,opt,name=restaurant_id,json=restaurantId,proto3" json:"restaurant_id,omitempty"`
+	// local date, formatted as 2006-01-02
+	Date          string `protobuf:"bytes,2,opt,name=date,proto3" json:"date,omitempty"`
+	PartySize     int32  `protobuf:"varint,3,opt,name=party_size,json=partySize,proto3" json:"party_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AvailabilityRequest) Reset() {
+	*x = AvailabilityRequest{}
+	mi := &file_reservation_reservation_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AvailabilityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AvailabilityRequest) ProtoMessage() {}
+
+func (x *AvailabilityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AvailabilityRequest.ProtoReflect.Descriptor instead.
+func (*AvailabilityRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AvailabilityRequest) GetRestaurantId() string {
+	if x != nil {
+		return x.RestaurantId
+	}
+	return ""
+}
+
+func (x *AvailabilityRequest) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *AvailabilityRequest) GetPartySize() int32 {
+	if x != nil {
+		return x.PartySize
+	}
+	return 0
+}
+
+type AvailabilityReply struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	RestaurantId string                 `protobuf:"bytes,1,opt,name=restaurant_id,json=restaurantId,proto3" json:"restaurant_id,omitempty"`
+	Date         string                 `protobuf:"bytes,2,opt,name=date,proto3" json:"date,omitempty"`
+	PartySize    int32                  `protobuf:"varint,3,opt,name=party_size,json=partySize,proto3" json:"party_size,omitempty"`
+	// RFC 3339 start times in the restaurant's timezone
+	Slots         []string `protobuf:"bytes,4,rep,name=slots,proto3" json:"slots,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AvailabilityReply) Reset() {
+	*x = AvailabilityReply{}
+	mi := &file_reservation_reservation_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AvailabilityReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AvailabilityReply) ProtoMessage() {}
+
+func (x *AvailabilityReply) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AvailabilityReply.ProtoReflect.Descriptor instead.
+func (*AvailabilityReply) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AvailabilityReply) GetRestaurantId() string {
+	if x != nil {
+		return x.RestaurantId
+	}
+	return ""
+}
+
+func (x *AvailabilityReply) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *AvailabilityReply) GetPartySize() int32 {
+	if x != nil {
+		return x.PartySize
+	}
+	return 0
+}
+
+func (x *AvailabilityReply) GetSlots() []string {
+	if x != nil {
+		return x.Slots
+	}
+	return nil
+}
+
+type Table struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Label         string                 `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+	Seats         int32                  `protobuf:"varint,3,opt,name=seats,proto3" json:"seats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Table) Reset() {
+	*x = Table{}
+	mi := &file_reservation_reservation_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Table) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Table) ProtoMessage() {}
+
+func (x *Table) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Table.ProtoReflect.Descriptor instead.
+func (*Table) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Table) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Table) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Table) GetSeats() int32 {
+	if x != nil {
+		return x.Seats
+	}
+	return 0
+}
+
+// OpeningHours is one opening interval on a weekday (0 = Sunday) in the restaurant's
+// local time, formatted as 15:04
+type OpeningHours struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Weekday       int32                  `protobuf:"varint,1,opt,name=weekday,proto3" json:"weekday,omitempty"`
+	Opens         string                 `protobuf:"bytes,2,opt,name=opens,proto3" json:"opens,omitempty"`
+	Closes        string                 `protobuf:"bytes,3,opt,name=closes,proto3" json:"closes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OpeningHours) Reset() {
+	*x = OpeningHours{}
+	mi := &file_reservation_reservation_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpeningHours) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpeningHours) ProtoMessage() {}
+
+func (x *OpeningHours) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpeningHours.ProtoReflect.Descriptor instead.
+func (*OpeningHours) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *OpeningHours) GetWeekday() int32 {
+	if x != nil {
+		return x.Weekday
+	}
+	return 0
+}
+
+func (x *OpeningHours) GetOpens() string {
+	if x != nil {
+		return x.Opens
+	}
+	return ""
+}
+
+func (x *OpeningHours) GetCloses() string {
+	if x != nil {
+		return x.Closes
+	}
+	return ""
+}
+
+type BlackoutDate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          string                 `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlackoutDate) Reset() {
+	*x = BlackoutDate{}
+	mi := &file_reservation_reservation_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlackoutDate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlackoutDate) ProtoMessage() {}
+
+func (x *BlackoutDate) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlackoutDate.ProtoReflect.Descriptor instead.
+func (*BlackoutDate) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BlackoutDate) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *BlackoutDate) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type Restaurant struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Address       string                 `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
+	Timezone      string                 `protobuf:"bytes,4,opt,name=timezone,proto3" json:"timezone,omitempty"`
+	Capacity      int32                  `protobuf:"varint,5,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Tables        []*Table               `protobuf:"bytes,6,rep,name=tables,proto3" json:"tables,omitempty"`
+	OpeningHours  []*OpeningHours        `protobuf:"bytes,7,rep,name=opening_hours,json=openingHours,proto3" json:"opening_hours,omitempty"`
+	BlackoutDates []*BlackoutDate        `protobuf:"bytes,8,rep,name=blackout_dates,json=blackoutDates,proto3" json:"blackout_dates,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Restaurant) Reset() {
+	*x = Restaurant{}
+	mi := &file_reservation_reservation_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Restaurant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Restaurant) ProtoMessage() {}
+
+func (x *Restaurant) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Restaurant.ProtoReflect.Descriptor instead.
+func (*Restaurant) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Restaurant) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Restaurant) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Restaurant) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Restaurant) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+func (x *Restaurant) GetCapacity() int32 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *Restaurant) GetTables() []*Table {
+	if x != nil {
+		return x.Tables
+	}
+	return nil
+}
+
+func (x *Restaurant) GetOpeningHours() []*OpeningHours {
+	if x != nil {
+		return x.OpeningHours
+	}
+	return nil
+}
+
+func (x *Restaurant) GetBlackoutDates() []*BlackoutDate {
+	if x != nil {
+		return x.BlackoutDates
+	}
+	return nil
+}
+
+func (x *Restaurant) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type RestaurantRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Caller        *Caller                `protobuf:"bytes,1,opt,name=caller,proto3" json:"caller,omitempty"`
+	Restaurant    *Restaurant            `protobuf:"bytes,2,opt,name=restaurant,proto3" json:"restaurant,omitempty"`
+	Page          int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize      int32                  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestaurantRequest) Reset() {
+	*x = RestaurantRequest{}
+	mi := &file_reservation_reservation_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestaurantRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestaurantRequest) ProtoMessage() {}
+
+func (x *RestaurantRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestaurantRequest.ProtoReflect.Descriptor instead.
+func (*RestaurantRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RestaurantRequest) GetCaller() *Caller {
+	if x != nil {
+		return x.Caller
+	}
+	return nil
+}
+
+func (x *RestaurantRequest) GetRestaurant() *Restaurant {
+	if x != nil {
+		return x.Restaurant
+	}
+	return nil
+}
+
+func (x *RestaurantRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *RestaurantRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+type CreateRestaurantReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRestaurantReply) Reset() {
+	*x = CreateRestaurantReply{}
+	mi := &file_reservation_reservation_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRestaurantReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRestaurantReply) ProtoMessage() {}
+
+func (x *CreateRestaurantReply) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRestaurantReply.ProtoReflect.Descriptor instead.
+func (*CreateRestaurantReply) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CreateRestaurantReply) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type RestaurantList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Restaurants   []*Restaurant          `protobuf:"bytes,1,rep,name=restaurants,proto3" json:"restaurants,omitempty"`
+	Page          int32                  `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize      int32                  `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	Total         int32                  `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestaurantList) Reset() {
+	*x = RestaurantList{}
+	mi := &file_reservation_reservation_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestaurantList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestaurantList) ProtoMessage() {}
+
+func (x *RestaurantList) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestaurantList.ProtoReflect.Descriptor instead.
+func (*RestaurantList) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *RestaurantList) GetRestaurants() []*Restaurant {
+	if x != nil {
+		return x.Restaurants
+	}
+	return nil
+}
+
+func (x *RestaurantList) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *RestaurantList) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *RestaurantList) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+// StaffRequest assigns a user to, or removes a user from, a restaurant's staff
+type StaffRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Caller        *Caller                `protobuf:"bytes,1,opt,name=caller,proto3" json:"caller,omitempty"`
+	RestaurantId  string                 `protobuf:"bytes,2,opt,name=restaurant_id,json=restaurantId,proto3" json:"restaurant_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Remove        bool                   `protobuf:"varint,4,opt,name=remove,proto3" json:"remove,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StaffRequest) Reset() {
+	*x = StaffRequest{}
+	mi := &file_reservation_reservation_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StaffRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StaffRequest) ProtoMessage() {}
+
+func (x *StaffRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StaffRequest.ProtoReflect.Descriptor instead.
+func (*StaffRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *StaffRequest) GetCaller() *Caller {
+	if x != nil {
+		return x.Caller
+	}
+	return nil
+}
+
+func (x *StaffRequest) GetRestaurantId() string {
+	if x != nil {
+		return x.RestaurantId
+	}
+	return ""
+}
+
+func (x *StaffRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *StaffRequest) GetRemove() bool {
+	if x != nil {
+		return x.Remove
+	}
+	return false
+}
+
+type AccountRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Caller        *Caller                `protobuf:"bytes,1,opt,name=caller,proto3" json:"caller,omitempty"`
+	DryRun        bool                   `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccountRequest) Reset() {
+	*x = AccountRequest{}
+	mi := &file_reservation_reservation_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccountRequest) ProtoMessage() {}
+
+func (x *AccountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccountRequest.ProtoReflect.Descriptor instead.
+func (*AccountRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AccountRequest) GetCaller() *Caller {
+	if x != nil {
+		return x.Caller
+	}
+	return nil
+}
+
+func (x *AccountRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type AccountDeletionReply struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// number of upcoming reservations of the user
+	Upcoming      int32 `protobuf:"varint,1,opt,name=upcoming,proto3" json:"upcoming,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccountDeletionReply) Reset() {
+	*x = AccountDeletionReply{}
+	mi := &file_reservation_reservation_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccountDeletionReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccountDeletionReply) ProtoMessage() {}
+
+func (x *AccountDeletionReply) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccountDeletionReply.ProtoReflect.Descriptor instead.
+func (*AccountDeletionReply) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *AccountDeletionReply) GetUpcoming() int32 {
+	if x != nil {
+		return x.Upcoming
+	}
+	return 0
+}
+
+type ReservationExport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reservations  []*Reservation         `protobuf:"bytes,1,rep,name=reservations,proto3" json:"reservations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReservationExport) Reset() {
+	*x = ReservationExport{}
+	mi := &file_reservation_reservation_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReservationExport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReservationExport) ProtoMessage() {}
+
+func (x *ReservationExport) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_reservation_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReservationExport.ProtoReflect.Descriptor instead.
+func (*ReservationExport) Descriptor() ([]byte, []int) {
+	return file_reservation_reservation_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ReservationExport) GetReservations() []*Reservation {
+	if x != nil {
+		return x.Reservations
+	}
+	return nil
+}
+
+var File_reservation_reservation_proto protoreflect.FileDescriptor
+
+const file_reservation_reservation_proto_rawDesc = "" +
+	"\n" +
+	"\x1dreservation/reservation.proto\x12\x0ereservation.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"5\n" +
+	"\x06Caller\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x12\n" +
+	"\x04role\x18\x02 \x01(\tR\x04role\">\n" +
+	"\x06Result\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x1a\n" +
+	"\breplayed\x18\x02 \x01(\bR\breplayed\"\xa5\x02\n" +
+	"\vReservation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rrestaurant_id\x18\x02 \x01(\tR\frestaurantId\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x05R\x05count\x12E\n" +
+	"\x10reservation_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x0freservationTime\x12\x18\n" +
+	"\aremarks\x18\x06 \x01(\tR\aremarks\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xdd\x01\n" +
+	"\x12ReservationRequest\x12.\n" +
+	"\x06caller\x18\x01 \x01(\v2\x16.reservation.v1.CallerR\x06caller\x12=\n" +
+	"\vreservation\x18\x02 \x01(\v2\x1b.reservation.v1.ReservationR\vreservation\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12'\n" +
+	"\x0fidempotency_key\x18\x05 \x01(\tR\x0eidempotencyKey\"\x99\x01\n" +
+	"\x0fReservationList\x12?\n" +
+	"\freservations\x18\x01 \x03(\v2\x1b.reservation.v1.ReservationR\freservations\x12\x12\n" +
+	"\x04page\x18\x02 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x05R\x05total\"m\n" +
+	"\x13AvailabilityRequest\x12#\n" +
+	"\rrestaurant_id\x18\x01 \x01(\tR\frestaurantId\x12\x12\n" +
+	"\x04date\x18\x02 \x01(\tR\x04date\x12\x1d\n" +
+	"\n" +
+	"party_size\x18\x03 \x01(\x05R\tpartySize\"\x81\x01\n" +
+	"\x11AvailabilityReply\x12#\n" +
+	"\rrestaurant_id\x18\x01 \x01(\tR\frestaurantId\x12\x12\n" +
+	"\x04date\x18\x02 \x01(\tR\x04date\x12\x1d\n" +
+	"\n" +
+	"party_size\x18\x03 \x01(\x05R\tpartySize\x12\x14\n" +
+	"\x05slots\x18\x04 \x03(\tR\x05slots\"C\n" +
+	"\x05Table\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05label\x18\x02 \x01(\tR\x05label\x12\x14\n" +
+	"\x05seats\x18\x03 \x01(\x05R\x05seats\"V\n" +
+	"\fOpeningHours\x12\x18\n" +
+	"\aweekday\x18\x01 \x01(\x05R\aweekday\x12\x14\n" +
+	"\x05opens\x18\x02 \x01(\tR\x05opens\x12\x16\n" +
+	"\x06closes\x18\x03 \x01(\tR\x06closes\":\n" +
+	"\fBlackoutDate\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\xf4\x02\n" +
+	"\n" +
+	"Restaurant\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
+	"\aaddress\x18\x03 \x01(\tR\aaddress\x12\x1a\n" +
+	"\btimezone\x18\x04 \x01(\tR\btimezone\x12\x1a\n" +
+	"\bcapacity\x18\x05 \x01(\x05R\bcapacity\x12-\n" +
+	"\x06tables\x18\x06 \x03(\v2\x15.reservation.v1.TableR\x06tables\x12A\n" +
+	"\ropening_hours\x18\a \x03(\v2\x1c.reservation.v1.OpeningHoursR\fopeningHours\x12C\n" +
+	"\x0eblackout_dates\x18\b \x03(\v2\x1c.reservation.v1.BlackoutDateR\rblackoutDates\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xb0\x01\n" +
+	"\x11RestaurantRequest\x12.\n" +
+	"\x06caller\x18\x01 \x01(\v2\x16.reservation.v1.CallerR\x06caller\x12:\n" +
+	"\n" +
+	"restaurant\x18\x02 \x01(\v2\x1a.reservation.v1.RestaurantR\n" +
+	"restaurant\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\"'\n" +
+	"\x15CreateRestaurantReply\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x95\x01\n" +
+	"\x0eRestaurantList\x12<\n" +
+	"\vrestaurants\x18\x01 \x03(\v2\x1a.reservation.v1.RestaurantR\vrestaurants\x12\x12\n" +
+	"\x04page\x18\x02 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x05R\x05total\"\x94\x01\n" +
+	"\fStaffRequest\x12.\n" +
+	"\x06caller\x18\x01 \x01(\v2\x16.reservation.v1.CallerR\x06caller\x12#\n" +
+	"\rrestaurant_id\x18\x02 \x01(\tR\frestaurantId\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x16\n" +
+	"\x06remove\x18\x04 \x01(\bR\x06remove\"Y\n" +
+	"\x0eAccountRequest\x12.\n" +
+	"\x06caller\x18\x01 \x01(\v2\x16.reservation.v1.CallerR\x06caller\x12\x17\n" +
+	"\adry_run\x18\x02 \x01(\bR\x06dryRun\"2\n" +
+	"\x14AccountDeletionReply\x12\x1a\n" +
+	"\bupcoming\x18\x01 \x01(\x05R\bupcoming\"T\n" +
+	"\x11ReservationExport\x12?\n" +
+	"\freservations\x18\x01 \x03(\v2\x1b.reservation.v1.ReservationR\freservations2\xbb\a\n" +
+	"\x12ReservationService\x12O\n" +
+	"\x11CreateReservation\x12\".reservation.v1.ReservationRequest\x1a\x16.reservation.v1.Result\x12Q\n" +
+	"\x0eGetReservation\x12\".reservation.v1.ReservationRequest\x1a\x1b.reservation.v1.Reservation\x12W\n" +
+	"\x10ListReservations\x12\".reservation.v1.ReservationRequest\x1a\x1f.reservation.v1.ReservationList\x12O\n" +
+	"\x11UpdateReservation\x12\".reservation.v1.ReservationRequest\x1a\x16.reservation.v1.Result\x12O\n" +
+	"\x11CancelReservation\x12\".reservation.v1.ReservationRequest\x1a\x16.reservation.v1.Result\x12V\n" +
+	"\fAvailability\x12#.reservation.v1.AvailabilityRequest\x1a!.reservation.v1.AvailabilityReply\x12\\\n" +
+	"\x10CreateRestaurant\x12!.reservation.v1.RestaurantRequest\x1a%.reservation.v1.CreateRestaurantReply\x12T\n" +
+	"\x0fListRestaurants\x12!.reservation.v1.RestaurantRequest\x1a\x1e.reservation.v1.RestaurantList\x12C\n" +
+	"\vAssignStaff\x12\x1c.reservation.v1.StaffRequest\x1a\x16.reservation.v1.Result\x12\\\n" +
+	"\x14ApplyAccountDeletion\x12\x1e.reservation.v1.AccountRequest\x1a$.reservation.v1.AccountDeletionReply\x12W\n" +
+	"\x12ExportReservations\x12\x1e.reservation.v1.AccountRequest\x1a!.reservation.v1.ReservationExportB!Z\x1fproto/reservation;reservationpbb\x06proto3"
+
+var (
+	file_reservation_reservation_proto_rawDescOnce sync.Once
+	file_reservation_reservation_proto_rawDescData []byte
+)
+
+func file_reservation_reservation_proto_rawDescGZIP() []byte {
+	file_reservation_reservation_proto_rawDescOnce.Do(func() {
+		file_reservation_reservation_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_reservation_reservation_proto_rawDesc), len(file_reservation_reservation_proto_rawDesc)))
+	})
+	return file_reservation_reservation_proto_rawDescData
+}
+
+var file_reservation_reservation_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_reservation_reservation_proto_goTypes = []any{
+	(*Caller)(nil),                // 0: reservation.v1.Caller
+	(*Result)(nil),                // 1: reservation.v1.Result
+	(*Reservation)(nil),           // 2: reservation.v1.Reservation
+	(*ReservationRequest)(nil),    // 3: reservation.v1.ReservationRequest
+	(*ReservationList)(nil),       // 4: reservation.v1.ReservationList
+	(*AvailabilityRequest)(nil),   // 5: reservation.v1.AvailabilityRequest
+	(*AvailabilityReply)(nil),     // 6: reservation.v1.AvailabilityReply
+	(*Table)(nil),                 // 7: reservation.v1.Table
+	(*OpeningHours)(nil),          // 8: reservation.v1.OpeningHours
+	(*BlackoutDate)(nil),          // 9: reservation.v1.BlackoutDate
+	(*Restaurant)(nil),            // 10: reservation.v1.Restaurant
+	(*RestaurantRequest)(nil),     // 11: reservation.v1.RestaurantRequest
+	(*CreateRestaurantReply)(nil), // 12: reservation.v1.CreateRestaurantReply
+	(*RestaurantList)(nil),        // 13: reservation.v1.RestaurantList
+	(*StaffRequest)(nil),          // 14: reservation.v1.StaffRequest
+	(*AccountRequest)(nil),        // 15: reservation.v1.AccountRequest
+	(*AccountDeletionReply)(nil),  // 16: reservation.v1.AccountDeletionReply
+	(*ReservationExport)(nil),     // 17: reservation.v1.ReservationExport
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
+}
+var file_reservation_reservation_proto_depIdxs = []int32{
+	18, // 0: reservation.v1.Reservation.reservation_time:type_name -> google.protobuf.Timestamp
+	18, // 1: reservation.v1.Reservation.created_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: reservation.v1.ReservationRequest.caller:type_name -> reservation.v1.Caller
+	2,  // 3: reservation.v1.ReservationRequest.reservation:type_name -> reservation.v1.Reservation
+	2,  // 4: reservation.v1.ReservationList.reservations:type_name -> reservation.v1.Reservation
+	7,  // 5: reservation.v1.Restaurant.tables:type_name -> reservation.v1.Table
+	8,  // 6: reservation.v1.Restaurant.opening_hours:type_name -> reservation.v1.OpeningHours
+	9,  // 7: reservation.v1.Restaurant.blackout_dates:type_name -> reservation.v1.BlackoutDate
+	18, // 8: reservation.v1.Restaurant.created_at:type_name -> google.protobuf.Timestamp
+	0,  // 9: reservation.v1.RestaurantRequest.caller:type_name -> reservation.v1.Caller
+	10, // 10: reservation.v1.RestaurantRequest.restaurant:type_name -> reservation.v1.Restaurant
+	10, // 11: reservation.v1.RestaurantList.restaurants:type_name -> reservation.v1.Restaurant
+	0,  // 12: reservation.v1.StaffRequest.caller:type_name -> reservation.v1.Caller
+	0,  // 13: reservation.v1.AccountRequest.caller:type_name -> reservation.v1.Caller
+	2,  // 14: reservation.v1.ReservationExport.reservations:type_name -> reservation.v1.Reservation
+	3,  // 15: reservation.v1.ReservationService.CreateReservation:input_type -> reservation.v1.ReservationRequest
+	3,  // 16: reservation.v1.ReservationService.GetReservation:input_type -> reservation.v1.ReservationRequest
+	3,  // 17: reservation.v1.ReservationService.ListReservations:input_type -> reservation.v1.ReservationRequest
+	3,  // 18: reservation.v1.ReservationService.UpdateReservation:input_type -> reservation.v1.ReservationRequest
+	3,  // 19: reservation.v1.ReservationService.CancelReservation:input_type -> reservation.v1.ReservationRequest
+	5,  // 20: reservation.v1.ReservationService.Availability:input_type -> reservation.v1.AvailabilityRequest
+	11, // 21: reservation.v1.ReservationService.CreateRestaurant:input_type -> reservation.v1.RestaurantRequest
+	11, // 22: reservation.v1.ReservationService.ListRestaurants:input_type -> reservation.v1.RestaurantRequest
+	14, // 23: reservation.v1.ReservationService.AssignStaff:input_type -> reservation.v1.StaffRequest
+	15, // 24: reservation.v1.ReservationService.ApplyAccountDeletion:input_type -> reservation.v1.AccountRequest
+	15, // 25: reservation.v1.ReservationService.ExportReservations:input_type -> reservation.v1.AccountRequest
+	1,  // 26: reservation.v1.ReservationService.CreateReservation:output_type -> reservation.v1.Result
+	2,  // 27: reservation.v1.ReservationService.GetReservation:output_type -> reservation.v1.Reservation
+	4,  // 28: reservation.v1.ReservationService.ListReservations:output_type -> reservation.v1.ReservationList
+	1,  // 29: reservation.v1.ReservationService.UpdateReservation:output_type -> reservation.v1.Result
+	1,  // 30: reservation.v1.ReservationService.CancelReservation:output_type -> reservation.v1.Result
+	6,  // 31: reservation.v1.ReservationService.Availability:output_type -> reservation.v1.AvailabilityReply
+	12, // 32: reservation.v1.ReservationService.CreateRestaurant:output_type -> reservation.v1.CreateRestaurantReply
+	13, // 33: reservation.v1.ReservationService.ListRestaurants:output_type -> reservation.v1.RestaurantList
+	1,  // 34: reservation.v1.ReservationService.AssignStaff:output_type -> reservation.v1.Result
+	16, // 35: reservation.v1.ReservationService.ApplyAccountDeletion:output_type -> reservation.v1.AccountDeletionReply
+	17, // 36: reservation.v1.ReservationService.ExportReservations:output_type -> reservation.v1.ReservationExport
+	26, // [26:37] is the sub-list for method output_type
+	15, // [15:26] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
+}
+
+func init() { file_reservation_reservation_proto_init() }
+func file_reservation_reservation_proto_init() {
+	if File_reservation_reservation_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_reservation_reservation_proto_rawDesc), len(file_reservation_reservation_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_reservation_reservation_proto_goTypes,
+		DependencyIndexes: file_reservation_reservation_proto_depIdxs,
+		MessageInfos:      file_reservation_reservation_proto_msgTypes,
+	}.Build()
+	File_reservation_reservation_proto = out.File
+	file_reservation_reservation_proto_goTypes = nil
+	file_reservation_reservation_proto_depIdxs = nil
+}
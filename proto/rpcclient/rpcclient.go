@@ -0,0 +1,152 @@
+// Package rpcclient is the client side the services use to call each other over
+// gRPC: a small pool of connections to one service that reconnect with backoff, and a
+// deadline on every call. Calls are traced, and pass the caller's trace context and
+// request ID on in the request metadata. A Pool is passed to the generated
+// New...Client functions.
+package rpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"proto/requestid"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+)
+
+// defaults for the zero fields of Config
+const (
+	DefaultSize        = 2
+	DefaultCallTimeout = 5 * time.Second
+	DefaultMaxBackoff  = 30 * time.Second
+
+	// minConnectTimeout is how long one connection attempt may take
+	minConnectTimeout = 5 * time.Second
+)
+
+// Config describes the connections to one service
+type Config struct {
+	// Addr is the service's host:port
+	Addr string
+
+	// TLS is the client side of the mutual TLS the RPC ports require
+	TLS *tls.Config
+
+	// Size is the number of connections the calls are spread over
+	Size int
+
+	// CallTimeout bounds every call whose context has no deadline of its own
+	CallTimeout time.Duration
+
+	// MaxBackoff caps the wait between attempts to reconnect a dropped connection
+	MaxBackoff time.Duration
+}
+
+// Pool holds the connections to one service. It is safe for concurrent use.
+type Pool struct {
+	conns       []*grpc.ClientConn
+	next        atomic.Uint64
+	callTimeout time.Duration
+}
+
+var _ grpc.ClientConnInterface = (*Pool)(nil)
+
+// New sets up the connections of a pool and starts connecting them in the background,
+// so a service that is down at startup doesn't stop the caller
+func New(cfg Config) (*Pool, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("rpcclient: address is required")
+	}
+	if cfg.TLS == nil {
+		return nil, errors.New("rpcclient: TLS config is required")
+	}
+	if cfg.Size < 1 {
+		cfg.Size = DefaultSize
+	}
+	if cfg.CallTimeout <= 0 {
+		cfg.CallTimeout = DefaultCallTimeout
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+
+	reconnect := backoff.DefaultConfig
+	reconnect.BaseDelay = 250 * time.Millisecond
+	reconnect.MaxDelay = cfg.MaxBackoff
+
+	p := &Pool{callTimeout: cfg.CallTimeout}
+
+	for i := 0; i < cfg.Size; i++ {
+		conn, err := grpc.NewClient(cfg.Addr,
+			grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLS)),
+			grpc.WithConnectParams(grpc.ConnectParams{
+				Backoff:           reconnect,
+				MinConnectTimeout: minConnectTimeout,
+			}),
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+			grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+		)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+
+		conn.Connect()
+		p.conns = append(p.conns, conn)
+	}
+
+	return p, nil
+}
+
+// Invoke makes a unary call on one of the pool's connections, adding the pool's call
+// timeout unless ctx already has a deadline
+func (p *Pool) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.callTimeout)
+		defer cancel()
+	}
+
+	return p.pick().Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream opens a stream on one of the pool's connections. Streams live as long as
+// ctx, so the call timeout is not applied.
+func (p *Pool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return p.pick().NewStream(ctx, desc, method, opts...)
+}
+
+// Close closes every connection of the pool. Calls in flight fail with
+// codes.Canceled.
+func (p *Pool) Close() error {
+	var errs []error
+	for _, conn := range p.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// pick returns the next connection in turn, skipping the ones that failed to connect
+// while another one is usable
+func (p *Pool) pick() *grpc.ClientConn {
+	start := p.next.Add(1)
+
+	for i := range p.conns {
+		conn := p.conns[(start+uint64(i))%uint64(len(p.conns))]
+		if conn.GetState() != connectivity.TransientFailure {
+			return conn
+		}
+	}
+
+	return p.conns[start%uint64(len(p.conns))]
+}
@@ -0,0 +1,361 @@
+package rpcclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// testCerts is a CA and a certificate for localhost it issued, used by both the test
+// server and the pools, as the services share theirs
+type testCerts struct {
+	server *tls.Config
+	client *tls.Config
+}
+
+func newTestCerts(t *testing.T) testCerts {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return testCerts{
+		server: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			MinVersion:   tls.VersionTLS13,
+		},
+		client: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      pool,
+			MinVersion:   tls.VersionTLS13,
+		},
+	}
+}
+
+// testServer serves the gRPC health service on addr, recording the deadline of the
+// last call it got
+type testServer struct {
+	*grpc.Server
+	addr string
+
+	mu           sync.Mutex
+	lastDeadline time.Time
+	hadDeadline  bool
+}
+
+func startTestServer(t *testing.T, certs testCerts, addr string) *testServer {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listening on %s: %v", addr, err)
+	}
+
+	ts := &testServer{addr: lis.Addr().String()}
+	ts.Server = grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(certs.server)),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ts.mu.Lock()
+			ts.lastDeadline, ts.hadDeadline = ctx.Deadline()
+			ts.mu.Unlock()
+
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(ts.Server, health.NewServer())
+
+	go func() { _ = ts.Serve(lis) }()
+	t.Cleanup(ts.Stop)
+
+	return ts
+}
+
+func (ts *testServer) deadline() (time.Time, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.lastDeadline, ts.hadDeadline
+}
+
+func check(ctx context.Context, p *Pool, opts ...grpc.CallOption) error {
+	_, err := healthpb.NewHealthClient(p).Check(ctx, &healthpb.HealthCheckRequest{}, opts...)
+	return err
+}
+
+// waitForState waits until conn reaches want
+func waitForState(t *testing.T, conn *grpc.ClientConn, want connectivity.State) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for state := conn.GetState(); state != want; state = conn.GetState() {
+		if !conn.WaitForStateChange(ctx, state) {
+			t.Fatalf("connection stayed %v, want %v", state, want)
+		}
+	}
+}
+
+// closedAddr returns a localhost address nothing listens on
+func closedAddr(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	return addr
+}
+
+func TestNewDefaults(t *testing.T) {
+	certs := newTestCerts(t)
+
+	if _, err := New(Config{TLS: certs.client}); err == nil {
+		t.Error("New succeeded without an address")
+	}
+	if _, err := New(Config{Addr: "127.0.0.1:1"}); err == nil {
+		t.Error("New succeeded without a TLS config")
+	}
+
+	p, err := New(Config{Addr: closedAddr(t), TLS: certs.client})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer p.Close()
+
+	if len(p.conns) != DefaultSize {
+		t.Errorf("pool has %d connections, want %d", len(p.conns), DefaultSize)
+	}
+	if p.callTimeout != DefaultCallTimeout {
+		t.Errorf("call timeout = %v, want %v", p.callTimeout, DefaultCallTimeout)
+	}
+}
+
+func TestPickRoundRobin(t *testing.T) {
+	certs := newTestCerts(t)
+	ts := startTestServer(t, certs, "127.0.0.1:0")
+
+	p, err := New(Config{Addr: ts.addr, TLS: certs.client, Size: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer p.Close()
+
+	seen := map[*grpc.ClientConn]int{}
+	for i := 0; i < 3*len(p.conns); i++ {
+		seen[p.pick()]++
+	}
+
+	if len(seen) != len(p.conns) {
+		t.Fatalf("picked %d distinct connections, want %d", len(seen), len(p.conns))
+	}
+	for conn, n := range seen {
+		if n != 3 {
+			t.Errorf("connection %p picked %d times, want 3", conn, n)
+		}
+	}
+}
+
+func TestPickSkipsTransientFailure(t *testing.T) {
+	certs := newTestCerts(t)
+	ts := startTestServer(t, certs, "127.0.0.1:0")
+
+	up, err := New(Config{Addr: ts.addr, TLS: certs.client, Size: 1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer up.Close()
+
+	down, err := New(Config{Addr: closedAddr(t), TLS: certs.client, Size: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer down.Close()
+
+	for _, conn := range down.conns {
+		waitForState(t, conn, connectivity.TransientFailure)
+	}
+
+	p := &Pool{conns: []*grpc.ClientConn{down.conns[0], up.conns[0], down.conns[1]}, callTimeout: time.Second}
+
+	for i := 0; i < 2*len(p.conns); i++ {
+		if conn := p.pick(); conn != up.conns[0] {
+			t.Fatalf("pick %d returned a connection in %v", i, conn.GetState())
+		}
+	}
+
+	if err = check(context.Background(), p); err != nil {
+		t.Fatalf("call through the pool: %v", err)
+	}
+
+	// with every connection failing, pick still hands one out, so the call fails
+	// rather than hanging
+	failing := &Pool{conns: down.conns, callTimeout: time.Second}
+	if conn := failing.pick(); conn == nil {
+		t.Fatal("pick returned no connection")
+	}
+}
+
+func TestInvokeCallTimeout(t *testing.T) {
+	certs := newTestCerts(t)
+	ts := startTestServer(t, certs, "127.0.0.1:0")
+
+	const callTimeout = 2 * time.Second
+
+	p, err := New(Config{Addr: ts.addr, TLS: certs.client, CallTimeout: callTimeout})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer p.Close()
+
+	start := time.Now()
+	if err = check(context.Background(), p); err != nil {
+		t.Fatalf("call without deadline: %v", err)
+	}
+	end := time.Now()
+
+	// the server takes the deadline from the time left when the call arrives
+	deadline, ok := ts.deadline()
+	if !ok {
+		t.Fatal("call without deadline reached the server without the pool's deadline")
+	}
+	if deadline.Before(start.Add(callTimeout-time.Second)) || deadline.After(end.Add(callTimeout)) {
+		t.Errorf("call without deadline got %v to run, want about %v", deadline.Sub(start), callTimeout)
+	}
+
+	// the caller's own deadline is kept, even when it is longer
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err = check(ctx, p); err != nil {
+		t.Fatalf("call with deadline: %v", err)
+	}
+
+	deadline, ok = ts.deadline()
+	if want, _ := ctx.Deadline(); !ok || want.Sub(deadline) > time.Second || deadline.Sub(want) > time.Second {
+		t.Errorf("call with deadline %v reached the server with deadline %v", want, deadline)
+	}
+}
+
+func TestReconnectAfterRestart(t *testing.T) {
+	certs := newTestCerts(t)
+	ts := startTestServer(t, certs, "127.0.0.1:0")
+
+	p, err := New(Config{Addr: ts.addr, TLS: certs.client, Size: 1, MaxBackoff: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer p.Close()
+
+	if err = check(context.Background(), p); err != nil {
+		t.Fatalf("call before restart: %v", err)
+	}
+
+	ts.Stop()
+	waitForState(t, p.conns[0], connectivity.Idle)
+
+	if err = check(context.Background(), p); status.Code(err) != codes.Unavailable {
+		t.Fatalf("call while the server is down: %v, want %v", err, codes.Unavailable)
+	}
+
+	startTestServer(t, certs, ts.addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err = check(ctx, p, grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("call after restart: %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	certs := newTestCerts(t)
+	ts := startTestServer(t, certs, "127.0.0.1:0")
+
+	p, err := New(Config{Addr: ts.addr, TLS: certs.client})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err = check(context.Background(), p); err != nil {
+		t.Fatalf("call before Close: %v", err)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for _, conn := range p.conns {
+		if state := conn.GetState(); state != connectivity.Shutdown {
+			t.Errorf("connection is %v after Close, want %v", state, connectivity.Shutdown)
+		}
+	}
+
+	if err = check(context.Background(), p); status.Code(err) != codes.Canceled {
+		t.Errorf("call after Close: %v, want %v", err, codes.Canceled)
+	}
+}
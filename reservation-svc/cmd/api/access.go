@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// roles a caller can have, as issued by auth-svc
+const (
+	roleCustomer = "customer"
+	roleStaff    = "restaurant_staff"
+	roleAdmin    = "admin"
+)
+
+// Caller is the authenticated user an RPC is made on behalf of. The broker fills it
+// from the verified JWT; it is never taken from the client's request body.
+type Caller struct {
+	UserID string
+	Role   string
+}
+
+// StaffPayload assigns a user to, or removes a user from, a restaurant's staff
+type StaffPayload struct {
+	Caller       Caller
+	RestaurantID string
+	UserID       string
+	Remove       bool
+	RequestID    string
+}
+
+var (
+	ErrForbidden    = errors.New("forbidden")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// reservationScope returns a WHERE condition limiting reservations to the ones the
+// caller may see and manage, with the caller's ID bound to placeholder $n. Customers
+// see their own reservations, staff also the ones at their restaurants, and admins all.
+func reservationScope(c Caller, n int) (string, []any) {
+	switch c.Role {
+	case roleAdmin:
+		return "TRUE", nil
+	case roleStaff:
+		return fmt.Sprintf(`(user_id = $%[1]d OR restaurant_id IN (
+			SELECT restaurant_id::TEXT FROM restaurant_staff WHERE user_id = $%[1]d))`, n), []any{c.UserID}
+	default:
+		return fmt.Sprintf("user_id = $%d", n), []any{c.UserID}
+	}
+}
+
+// canManageRestaurant reports whether the caller may see all reservations of a
+// restaurant: admins for every restaurant, staff for the ones they are assigned to
+func canManageRestaurant(ctx context.Context, c Caller, restaurantID string) (bool, error) {
+	switch c.Role {
+	case roleAdmin:
+		return true, nil
+	case roleStaff:
+		if _, err := strconv.Atoi(restaurantID); err != nil {
+			return false, nil
+		}
+
+		var ok bool
+		err := conn.QueryRowContext(ctx, `SELECT EXISTS (
+			SELECT 1 FROM restaurant_staff WHERE restaurant_id = $1 AND user_id = $2
+		)`, restaurantID, c.UserID).Scan(&ok)
+		return ok, err
+	default:
+		return false, nil
+	}
+}
+
+// AssignStaff adds a user to a restaurant's staff, or removes them with Remove set.
+// Only admins can change staff assignments; the user's restaurant_staff role itself
+// is granted in auth-svc.
+func (r *RPCServer) AssignStaff(payload StaffPayload, resp *string) error {
+	if payload.Caller.Role != roleAdmin {
+		return ErrForbidden
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if _, err := restaurantLocation(ctx, payload.RestaurantID); err != nil {
+		return err
+	}
+
+	if _, err := strconv.Atoi(payload.UserID); err != nil {
+		return ErrUserNotFound
+	}
+
+	var err error
+	if payload.Remove {
+		_, err = conn.ExecContext(ctx, `DELETE FROM restaurant_staff WHERE restaurant_id = $1 AND user_id = $2`,
+			payload.RestaurantID, payload.UserID)
+	} else {
+		_, err = conn.ExecContext(ctx, `INSERT INTO restaurant_staff (restaurant_id, user_id)
+		VALUES ($1, $2) ON CONFLICT DO NOTHING`, payload.RestaurantID, payload.UserID)
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrUserNotFound
+		}
+		log.Println("Error updating restaurant_staff via RPC: ", err)
+		return err
+	}
+
+	action := "assigned to"
+	if payload.Remove {
+		action = "removed from"
+	}
+	successMsg := fmt.Sprintf("User: %s %s restaurant: %s by admin: %s",
+		payload.UserID, action, payload.RestaurantID, payload.Caller.UserID)
+
+	log.Println(successMsg)
+
+	logItemViaRPC(LogPayload{
+		Name:      "Restaurant_StaffChanged",
+		Data:      successMsg,
+		RequestID: payload.RequestID,
+	})
+
+	*resp = "Restaurant staff updated successfully"
+	return nil
+}
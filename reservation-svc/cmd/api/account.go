@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// What happens to a user's upcoming reservations when they delete their account, set
+// with ACCOUNT_DELETION_RESERVATIONS
+const (
+	// cancel them, freeing the tables
+	deletionPolicyCancel = "cancel"
+	// keep them; the restaurant still expects the guests
+	deletionPolicyKeep = "keep"
+	// refuse to delete the account until the user cancels them
+	deletionPolicyReject = "reject"
+)
+
+var deletionPolicy = deletionPolicyCancel
+
+// AccountPayload identifies the account an account level RPC acts on. With DryRun set,
+// ApplyAccountDeletion only checks whether the deletion would be allowed.
+type AccountPayload struct {
+	Caller    Caller
+	DryRun    bool
+	RequestID string
+}
+
+var ErrUpcomingReservations = errors.New("account has upcoming reservations")
+
+// validDeletionPolicy reports whether policy is one of the known deletion policies
+func validDeletionPolicy(policy string) bool {
+	switch policy {
+	case deletionPolicyCancel, deletionPolicyKeep, deletionPolicyReject:
+		return true
+	}
+	return false
+}
+
+// ApplyAccountDeletion deals with the reservations of a user whose account is being
+// deleted, and replies with the number of upcoming reservations. Upcoming reservations
+// are handled per deletionPolicy; remarks on all others are cleared, as they may hold
+// personal data. Staff assignments of the user are removed.
+func (r *RPCServer) ApplyAccountDeletion(payload AccountPayload, resp *int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	userID := payload.Caller.UserID
+	now := time.Now()
+
+	var upcoming int
+	err := conn.QueryRowContext(ctx, `SELECT count(*) FROM reservations
+	WHERE user_id = $1 AND status <> $2 AND reservation_time > $3`,
+		userID, statusCancelled, now,
+	).Scan(&upcoming)
+	if err != nil {
+		log.Println("Error counting upcoming reservations via RPC: ", err)
+		return err
+	}
+
+	if upcoming > 0 && deletionPolicy == deletionPolicyReject {
+		return ErrUpcomingReservations
+	}
+
+	if payload.DryRun {
+		*resp = upcoming
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("Error starting account deletion transaction via RPC: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if deletionPolicy == deletionPolicyCancel {
+		_, err = tx.ExecContext(ctx, `UPDATE reservations SET
+			status = $2,
+			cancelled_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND status <> $2 AND reservation_time > $3`,
+			userID, statusCancelled, now)
+		if err != nil {
+			log.Println("Error cancelling reservations of deleted account via RPC: ", err)
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE reservations SET remarks = NULL, updated_at = CURRENT_TIMESTAMP
+	WHERE user_id = $1 AND remarks IS NOT NULL AND (status = $2 OR reservation_time <= $3)`,
+		userID, statusCancelled, now)
+	if err != nil {
+		log.Println("Error clearing remarks of deleted account via RPC: ", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM restaurant_staff WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Println("Error removing staff assignments of deleted account via RPC: ", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing account deletion via RPC: ", err)
+		return err
+	}
+
+	successMsg := fmt.Sprintf("Reservations of userID: %s handled for account deletion, %d upcoming (policy: %s)",
+		userID, upcoming, deletionPolicy)
+
+	log.Println(successMsg)
+
+	logItemViaRPC(LogPayload{
+		Name:      "Reservation_AccountDeleted",
+		Data:      successMsg,
+		RequestID: payload.RequestID,
+	})
+
+	*resp = upcoming
+	return nil
+}
+
+// ExportReservations returns every reservation of the caller, cancelled ones included,
+// oldest first
+func (r *RPCServer) ExportReservations(payload AccountPayload, resp *[]ReservationData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := conn.QueryContext(ctx, `SELECT `+reservationColumns+` FROM reservations
+	WHERE user_id = $1 ORDER BY created_at, id`, payload.Caller.UserID)
+	if err != nil {
+		log.Println("Error exporting reservations via RPC: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	reservations := []ReservationData{}
+	for rows.Next() {
+		rd, err := scanReservation(rows)
+		if err != nil {
+			log.Println("Error scanning reservation via RPC: ", err)
+			return err
+		}
+		reservations = append(reservations, *rd)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Println("Error iterating reservations via RPC: ", err)
+		return err
+	}
+
+	*resp = reservations
+	return nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A reservation holds its table for diningDuration, and availability is offered in
+// steps of slotInterval from each opening time.
+const (
+	slotInterval   = 30 * time.Minute
+	diningDuration = 90 * time.Minute
+)
+
+type AvailabilityPayload struct {
+	RestaurantID string
+	Date         string
+	PartySize    int
+}
+
+// Availability is the reply of the Availability RPC. Slots are RFC 3339 start times in
+// the restaurant's timezone.
+type Availability struct {
+	RestaurantID string   `json:"restaurantID"`
+	Date         string   `json:"date"`
+	PartySize    int      `json:"partySize"`
+	Slots        []string `json:"slots"`
+}
+
+var (
+	ErrInvalidPartySize = errors.New("invalid party size")
+	ErrInvalidDate      = errors.New("invalid date")
+	ErrNoTableAvailable = errors.New("no table available at that time")
+)
+
+// Availability returns the start times on a date at which a table for the party is free
+func (r *RPCServer) Availability(payload AvailabilityPayload, resp *Availability) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if payload.PartySize < 1 {
+		return ErrInvalidPartySize
+	}
+
+	loc, err := restaurantLocation(ctx, payload.RestaurantID)
+	if err != nil {
+		return err
+	}
+
+	day, err := time.ParseInLocation(dateLayout, payload.Date, loc)
+	if err != nil {
+		return ErrInvalidDate
+	}
+
+	result := Availability{
+		RestaurantID: payload.RestaurantID,
+		Date:         payload.Date,
+		PartySize:    payload.PartySize,
+		Slots:        []string{},
+	}
+
+	var closed bool
+	err = conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM restaurant_blackout_dates WHERE restaurant_id = $1 AND blackout_date = $2
+	)`, payload.RestaurantID, payload.Date).Scan(&closed)
+	if err != nil {
+		log.Println("Error checking blackout dates via RPC: ", err)
+		return err
+	}
+	if closed {
+		*resp = result
+		return nil
+	}
+
+	tables, err := tablesForParty(ctx, payload.RestaurantID, payload.PartySize)
+	if err != nil {
+		log.Println("Error getting tables via RPC: ", err)
+		return err
+	}
+	if len(tables) == 0 {
+		*resp = result
+		return nil
+	}
+
+	booked, err := bookedTimes(ctx, tables, day.Add(-diningDuration), day.AddDate(0, 0, 1).Add(diningDuration))
+	if err != nil {
+		log.Println("Error getting booked tables via RPC: ", err)
+		return err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI')
+	FROM restaurant_opening_hours WHERE restaurant_id = $1 AND weekday = $2 ORDER BY opens_at`,
+		payload.RestaurantID, int(day.Weekday()))
+	if err != nil {
+		log.Println("Error getting opening hours via RPC: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		var opens, closes string
+		if err = rows.Scan(&opens, &closes); err != nil {
+			log.Println("Error scanning opening hours via RPC: ", err)
+			return err
+		}
+
+		slots, err := slotStarts(day, opens, closes)
+		if err != nil {
+			return err
+		}
+
+		for _, slot := range slots {
+			if slot.Before(now) {
+				continue
+			}
+			if hasFreeTable(tables, booked, slot) {
+				result.Slots = append(result.Slots, slot.Format(time.RFC3339))
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Println("Error iterating opening hours via RPC: ", err)
+		return err
+	}
+
+	*resp = result
+	return nil
+}
+
+// assignTable picks the smallest table that seats the party and is free around the
+// given time. It locks every table of the restaurant first, so concurrent bookings for
+// the same restaurant are serialized until tx ends. excludeID is a reservation to ignore
+// when looking for overlaps, used when a reservation is moved.
+func assignTable(ctx context.Context, tx *sql.Tx, restaurantID string, at time.Time, partySize int, excludeID string) (string, error) {
+	if _, err := strconv.Atoi(restaurantID); err != nil {
+		return "", ErrRestaurantNotFound
+	}
+
+	_, err := tx.ExecContext(ctx, `SELECT id FROM restaurant_tables WHERE restaurant_id = $1 FOR UPDATE`, restaurantID)
+	if err != nil {
+		return "", err
+	}
+
+	exclude := 0
+	if excludeID != "" {
+		if exclude, err = strconv.Atoi(excludeID); err != nil {
+			return "", ErrReservationNotFound
+		}
+	}
+
+	var tableID string
+	err = tx.QueryRowContext(ctx, `SELECT t.id FROM restaurant_tables t
+	WHERE t.restaurant_id = $1 AND t.seats >= $2
+	AND NOT EXISTS (
+		SELECT 1 FROM reservations r
+		WHERE r.table_id = t.id
+		AND r.status <> $3
+		AND r.id <> $4
+		AND r.reservation_time > $5::TIMESTAMPTZ - make_interval(mins => $6)
+		AND r.reservation_time < $5::TIMESTAMPTZ + make_interval(mins => $6)
+	)
+	ORDER BY t.seats, t.id
+	LIMIT 1`,
+		restaurantID,
+		partySize,
+		statusCancelled,
+		exclude,
+		at,
+		int(diningDuration/time.Minute),
+	).Scan(&tableID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoTableAvailable
+		}
+		return "", err
+	}
+
+	return tableID, nil
+}
+
+// restaurantLocation returns the timezone of a restaurant
+func restaurantLocation(ctx context.Context, restaurantID string) (*time.Location, error) {
+	if _, err := strconv.Atoi(restaurantID); err != nil {
+		return nil, ErrRestaurantNotFound
+	}
+
+	var timezone string
+	err := conn.QueryRowContext(ctx, `SELECT timezone FROM restaurants WHERE id = $1`, restaurantID).Scan(&timezone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRestaurantNotFound
+		}
+		return nil, err
+	}
+
+	return time.LoadLocation(timezone)
+}
+
+// tablesForParty returns the IDs of the restaurant's tables that seat the party
+func tablesForParty(ctx context.Context, restaurantID string, partySize int) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT id FROM restaurant_tables
+	WHERE restaurant_id = $1 AND seats >= $2 ORDER BY seats, id`, restaurantID, partySize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		tables = append(tables, id)
+	}
+
+	return tables, rows.Err()
+}
+
+// bookedTimes returns the start times of active reservations per table in [from, to)
+func bookedTimes(ctx context.Context, tables []string, from, to time.Time) (map[string][]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT table_id, reservation_time FROM reservations
+	WHERE table_id = ANY($1::INT[]) AND status <> $2 AND reservation_time >= $3 AND reservation_time < $4`,
+		pq.Array(tables), statusCancelled, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	booked := map[string][]time.Time{}
+	for rows.Next() {
+		var tableID string
+		var at time.Time
+		if err = rows.Scan(&tableID, &at); err != nil {
+			return nil, err
+		}
+		booked[tableID] = append(booked[tableID], at)
+	}
+
+	return booked, rows.Err()
+}
+
+// hasFreeTable reports whether any of the tables has no reservation overlapping slot
+func hasFreeTable(tables []string, booked map[string][]time.Time, slot time.Time) bool {
+	for _, id := range tables {
+		free := true
+		for _, at := range booked[id] {
+			if at.After(slot.Add(-diningDuration)) && at.Before(slot.Add(diningDuration)) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return true
+		}
+	}
+
+	return false
+}
+
+// slotStarts returns the start times offered between opening and closing on day:
+// every slotInterval from opens, as long as a reservation starting then ends by closes
+func slotStarts(day time.Time, opens, closes string) ([]time.Time, error) {
+	start, err := atClock(day, opens)
+	if err != nil {
+		return nil, err
+	}
+	end, err := atClock(day, closes)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []time.Time
+	for slot := start; !slot.Add(diningDuration).After(end); slot = slot.Add(slotInterval) {
+		slots = append(slots, slot)
+	}
+
+	return slots, nil
+}
+
+// atClock returns the given HH:MM on day, in day's location. A closing time of
+// endOfDay is the midnight that ends day.
+func atClock(day time.Time, clock string) (time.Time, error) {
+	if clock == endOfDay {
+		return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location()), nil
+	}
+
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlotStarts(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2025, time.June, 6, 0, 0, 0, 0, loc)
+
+	tests := []struct {
+		name          string
+		opens, closes string
+		want          []string
+	}{
+		{
+			name:  "last slot ends at closing time",
+			opens: "18:00", closes: "21:00",
+			want: []string{"18:00", "18:30", "19:00", "19:30"},
+		},
+		{
+			name:  "open until midnight",
+			opens: "21:00", closes: endOfDay,
+			want: []string{"21:00", "21:30", "22:00", "22:30"},
+		},
+		{
+			name:  "exactly one dining duration",
+			opens: "12:00", closes: "13:30",
+			want: []string{"12:00"},
+		},
+		{
+			name:  "shorter than a dining duration",
+			opens: "12:00", closes: "13:00",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		slots, err := slotStarts(day, tt.opens, tt.closes)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+		for _, slot := range slots {
+			if slot.Location() != loc || slot.Day() != day.Day() {
+				t.Errorf("%s: slot %v is not on %v in %v", tt.name, slot, day.Format(dateLayout), loc)
+			}
+			got = append(got, slot.Format(clockLayout))
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: slots %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: slots %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAtClockEndOfDay(t *testing.T) {
+	day := time.Date(2025, time.March, 29, 0, 0, 0, 0, time.UTC)
+
+	got, err := atClock(day, endOfDay)
+	if err != nil {
+		t.Fatalf("atClock(%q): %v", endOfDay, err)
+	}
+	if want := time.Date(2025, time.March, 30, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("atClock(%q) = %v, want %v", endOfDay, got, want)
+	}
+
+	if _, err = atClock(day, "25:00"); err == nil {
+		t.Error("atClock accepted 25:00")
+	}
+}
+
+func TestValidateOpeningHours(t *testing.T) {
+	restaurant := func(h OpeningHours) *RestaurantData {
+		return &RestaurantData{
+			Name:         "Test",
+			Tables:       []TableData{{Label: "T1", Seats: 2}},
+			OpeningHours: []OpeningHours{h},
+		}
+	}
+
+	for _, h := range []OpeningHours{
+		{Weekday: 5, Opens: "18:00", Closes: endOfDay},
+		{Weekday: 5, Opens: "00:00", Closes: "23:59"},
+	} {
+		if err := validateRestaurant(restaurant(h)); err != nil {
+			t.Errorf("%+v: %v", h, err)
+		}
+	}
+
+	for _, h := range []OpeningHours{
+		{Weekday: 5, Opens: endOfDay, Closes: endOfDay},
+		{Weekday: 5, Opens: "22:00", Closes: "18:00"},
+	} {
+		if err := validateRestaurant(restaurant(h)); err == nil {
+			t.Errorf("%+v was accepted", h)
+		}
+	}
+}
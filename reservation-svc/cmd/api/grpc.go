@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"proto/requestid"
+	reservationpb "proto/reservation"
+	"proto/telemetry"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer serves the ReservationService contract from proto/reservation. It
+// converts the messages and hands the calls to RPCServer, which also still backs the
+// gob endpoint while callers migrate.
+type grpcServer struct {
+	reservationpb.UnimplementedReservationServiceServer
+	rpc RPCServer
+}
+
+// rpcErrorCodes are the gRPC codes of the errors callers may pass on to the user. Any
+// other error is returned with codes.Unknown.
+var rpcErrorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{ErrReservationNotFound, codes.NotFound},
+	{ErrReservationCancelled, codes.FailedPrecondition},
+	{ErrRestaurantNotFound, codes.NotFound},
+	{ErrInvalidReservationTime, codes.InvalidArgument},
+	{ErrReservationInPast, codes.InvalidArgument},
+	{ErrOutsideOpeningHours, codes.InvalidArgument},
+	{ErrRestaurantClosed, codes.InvalidArgument},
+	{ErrInvalidRestaurant, codes.InvalidArgument},
+	{ErrForbidden, codes.PermissionDenied},
+	{ErrUserNotFound, codes.NotFound},
+	{ErrUpcomingReservations, codes.FailedPrecondition},
+	{ErrIdempotencyKeyReused, codes.AlreadyExists},
+	{ErrInvalidIdempotencyKey, codes.InvalidArgument},
+}
+
+// grpcListen serves the ReservationService over mutual TLS until the listener fails, or
+// until SIGINT or SIGTERM, after which the calls in flight are finished
+func grpcListen() error {
+	log.Println("Starting Reservation gRPC server on port: ", grpcPort)
+	listen, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%s", grpcPort))
+	if err != nil {
+		log.Println("Error starting gRPC server: ", err)
+		return err
+	}
+
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(rpcServerTLS)),
+		grpc.ConnectionTimeout(rpcHandshakeTimeout),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(requestid.UnaryServerInterceptor()),
+	)
+	reservationpb.RegisterReservationServiceServer(srv, &grpcServer{})
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		log.Println("Shutting down Reservation gRPC server")
+		srv.GracefulStop()
+	}()
+
+	return srv.Serve(listen)
+}
+
+// rpcStatus turns an RPCServer error into a gRPC status error
+func rpcStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, known := range rpcErrorCodes {
+		if errors.Is(err, known.err) {
+			return status.Error(known.code, err.Error())
+		}
+	}
+
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func (s *grpcServer) CreateReservation(ctx context.Context, req *reservationpb.ReservationRequest) (*reservationpb.Result, error) {
+	message, replayed, err := createReservation(ctx, rpcPayloadFromProto(ctx, req))
+	if err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.Result{Message: message, Replayed: replayed}, nil
+}
+
+func (s *grpcServer) GetReservation(ctx context.Context, req *reservationpb.ReservationRequest) (*reservationpb.Reservation, error) {
+	var resp ReservationData
+	if err := s.rpc.GetReservation(rpcPayloadFromProto(ctx, req), &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return reservationToProto(resp), nil
+}
+
+func (s *grpcServer) ListReservations(ctx context.Context, req *reservationpb.ReservationRequest) (*reservationpb.ReservationList, error) {
+	var resp ReservationList
+	if err := s.rpc.ListReservations(rpcPayloadFromProto(ctx, req), &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.ReservationList{
+		Reservations: reservationsToProto(resp.Reservations),
+		Page:         int32(resp.Page),
+		PageSize:     int32(resp.PageSize),
+		Total:        int32(resp.Total),
+	}, nil
+}
+
+func (s *grpcServer) UpdateReservation(ctx context.Context, req *reservationpb.ReservationRequest) (*reservationpb.Result, error) {
+	var resp string
+	if err := s.rpc.UpdateReservation(rpcPayloadFromProto(ctx, req), &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.Result{Message: resp}, nil
+}
+
+func (s *grpcServer) CancelReservation(ctx context.Context, req *reservationpb.ReservationRequest) (*reservationpb.Result, error) {
+	var resp string
+	if err := s.rpc.CancelReservation(rpcPayloadFromProto(ctx, req), &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.Result{Message: resp}, nil
+}
+
+func (s *grpcServer) Availability(_ context.Context, req *reservationpb.AvailabilityRequest) (*reservationpb.AvailabilityReply, error) {
+	payload := AvailabilityPayload{
+		RestaurantID: req.GetRestaurantId(),
+		Date:         req.GetDate(),
+		PartySize:    int(req.GetPartySize()),
+	}
+
+	var resp Availability
+	if err := s.rpc.Availability(payload, &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.AvailabilityReply{
+		RestaurantId: resp.RestaurantID,
+		Date:         resp.Date,
+		PartySize:    int32(resp.PartySize),
+		Slots:        resp.Slots,
+	}, nil
+}
+
+func (s *grpcServer) CreateRestaurant(ctx context.Context, req *reservationpb.RestaurantRequest) (*reservationpb.CreateRestaurantReply, error) {
+	var resp string
+	if err := s.rpc.CreateRestaurant(restaurantPayloadFromProto(ctx, req), &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.CreateRestaurantReply{Id: resp}, nil
+}
+
+func (s *grpcServer) ListRestaurants(ctx context.Context, req *reservationpb.RestaurantRequest) (*reservationpb.RestaurantList, error) {
+	var resp RestaurantList
+	if err := s.rpc.ListRestaurants(restaurantPayloadFromProto(ctx, req), &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	restaurants := make([]*reservationpb.Restaurant, 0, len(resp.Restaurants))
+	for _, rd := range resp.Restaurants {
+		restaurants = append(restaurants, restaurantToProto(rd))
+	}
+
+	return &reservationpb.RestaurantList{
+		Restaurants: restaurants,
+		Page:        int32(resp.Page),
+		PageSize:    int32(resp.PageSize),
+		Total:       int32(resp.Total),
+	}, nil
+}
+
+func (s *grpcServer) AssignStaff(ctx context.Context, req *reservationpb.StaffRequest) (*reservationpb.Result, error) {
+	payload := StaffPayload{
+		Caller:       callerFromProto(req.GetCaller()),
+		RestaurantID: req.GetRestaurantId(),
+		UserID:       req.GetUserId(),
+		Remove:       req.GetRemove(),
+		RequestID:    requestid.FromContext(ctx),
+	}
+
+	var resp string
+	if err := s.rpc.AssignStaff(payload, &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.Result{Message: resp}, nil
+}
+
+func (s *grpcServer) ApplyAccountDeletion(ctx context.Context, req *reservationpb.AccountRequest) (*reservationpb.AccountDeletionReply, error) {
+	payload := AccountPayload{
+		Caller:    callerFromProto(req.GetCaller()),
+		DryRun:    req.GetDryRun(),
+		RequestID: requestid.FromContext(ctx),
+	}
+
+	var resp int
+	if err := s.rpc.ApplyAccountDeletion(payload, &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.AccountDeletionReply{Upcoming: int32(resp)}, nil
+}
+
+func (s *grpcServer) ExportReservations(ctx context.Context, req *reservationpb.AccountRequest) (*reservationpb.ReservationExport, error) {
+	payload := AccountPayload{Caller: callerFromProto(req.GetCaller()), RequestID: requestid.FromContext(ctx)}
+
+	var resp []ReservationData
+	if err := s.rpc.ExportReservations(payload, &resp); err != nil {
+		return nil, rpcStatus(err)
+	}
+
+	return &reservationpb.ReservationExport{Reservations: reservationsToProto(resp)}, nil
+}
+
+func callerFromProto(c *reservationpb.Caller) Caller {
+	return Caller{
+		UserID: c.GetUserId(),
+		Role:   c.GetRole(),
+	}
+}
+
+// rpcPayloadFromProto converts a request, stamping it with the request ID of ctx
+func rpcPayloadFromProto(ctx context.Context, req *reservationpb.ReservationRequest) RPCPayload {
+	rd := req.GetReservation()
+
+	return RPCPayload{
+		Caller: callerFromProto(req.GetCaller()),
+		ReservationData: ReservationData{
+			ReservationID:   rd.GetId(),
+			RestaurantID:    rd.GetRestaurantId(),
+			UserId:          rd.GetUserId(),
+			Count:           int(rd.GetCount()),
+			ReservationTime: timeFromProto(rd.GetReservationTime()),
+			Remarks:         rd.GetRemarks(),
+		},
+		Page:           int(req.GetPage()),
+		PageSize:       int(req.GetPageSize()),
+		IdempotencyKey: req.GetIdempotencyKey(),
+		RequestID:      requestid.FromContext(ctx),
+		TraceContext:   telemetry.Inject(ctx),
+	}
+}
+
+func reservationToProto(rd ReservationData) *reservationpb.Reservation {
+	return &reservationpb.Reservation{
+		Id:              rd.ReservationID,
+		RestaurantId:    rd.RestaurantID,
+		UserId:          rd.UserId,
+		Count:           int32(rd.Count),
+		ReservationTime: timeToProto(rd.ReservationTime),
+		Remarks:         rd.Remarks,
+		Status:          rd.Status,
+		CreatedAt:       timeToProto(rd.CreatedAt),
+	}
+}
+
+func reservationsToProto(reservations []ReservationData) []*reservationpb.Reservation {
+	out := make([]*reservationpb.Reservation, 0, len(reservations))
+	for _, rd := range reservations {
+		out = append(out, reservationToProto(rd))
+	}
+	return out
+}
+
+func restaurantPayloadFromProto(ctx context.Context, req *reservationpb.RestaurantRequest) RestaurantPayload {
+	r := req.GetRestaurant()
+
+	rd := RestaurantData{
+		RestaurantID: r.GetId(),
+		Name:         r.GetName(),
+		Address:      r.GetAddress(),
+		Timezone:     r.GetTimezone(),
+		Capacity:     int(r.GetCapacity()),
+	}
+	for _, t := range r.GetTables() {
+		rd.Tables = append(rd.Tables, TableData{TableID: t.GetId(), Label: t.GetLabel(), Seats: int(t.GetSeats())})
+	}
+	for _, h := range r.GetOpeningHours() {
+		rd.OpeningHours = append(rd.OpeningHours, OpeningHours{Weekday: int(h.GetWeekday()), Opens: h.GetOpens(), Closes: h.GetCloses()})
+	}
+	for _, b := range r.GetBlackoutDates() {
+		rd.BlackoutDates = append(rd.BlackoutDates, BlackoutDate{Date: b.GetDate(), Reason: b.GetReason()})
+	}
+
+	return RestaurantPayload{
+		Caller:         callerFromProto(req.GetCaller()),
+		RestaurantData: rd,
+		Page:           int(req.GetPage()),
+		PageSize:       int(req.GetPageSize()),
+		RequestID:      requestid.FromContext(ctx),
+	}
+}
+
+func restaurantToProto(rd RestaurantData) *reservationpb.Restaurant {
+	r := &reservationpb.Restaurant{
+		Id:        rd.RestaurantID,
+		Name:      rd.Name,
+		Address:   rd.Address,
+		Timezone:  rd.Timezone,
+		Capacity:  int32(rd.Capacity),
+		CreatedAt: timeToProto(rd.CreatedAt),
+	}
+	for _, t := range rd.Tables {
+		r.Tables = append(r.Tables, &reservationpb.Table{Id: t.TableID, Label: t.Label, Seats: int32(t.Seats)})
+	}
+	for _, h := range rd.OpeningHours {
+		r.OpeningHours = append(r.OpeningHours, &reservationpb.OpeningHours{Weekday: int32(h.Weekday), Opens: h.Opens, Closes: h.Closes})
+	}
+	for _, b := range rd.BlackoutDates {
+		r.BlackoutDates = append(r.BlackoutDates, &reservationpb.BlackoutDate{Date: b.Date, Reason: b.Reason})
+	}
+
+	return r
+}
+
+// timeToProto leaves zero times unset, so they read back as zero
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// idempotencyKeyTTL is how long a key is remembered, set with IDEMPOTENCY_KEY_TTL. A
+// request repeated after that books again.
+var idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyPurgeInterval is how often the expired keys are deleted
+const idempotencyPurgeInterval = time.Hour
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	ErrIdempotencyKeyReused  = errors.New("idempotency key already used for a different request")
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+)
+
+// reservationFingerprint identifies the reservation request a key was first used
+// with, so the key can't be replayed for a different one
+func reservationFingerprint(rd ReservationData) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d\n%s\n%s",
+		rd.RestaurantID,
+		rd.Count,
+		rd.ReservationTime.UTC().Format(time.RFC3339Nano),
+		rd.Remarks,
+	)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func validIdempotencyKey(key string) bool {
+	return utf8.ValidString(key) && len(key) <= maxIdempotencyKeyLength
+}
+
+// claimIdempotencyKey records the user's key within tx. If a request with the key was
+// already made and the key has not expired, its response is returned with replay set;
+// a concurrent request with the same key waits here until the first one is done. The
+// key is kept only if tx commits, so a failed request can be retried with it.
+func claimIdempotencyKey(ctx context.Context, tx *sql.Tx, userID, key, fingerprint string) (string, bool, error) {
+	_, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys
+	WHERE user_id = $1 AND key = $2 AND expires_at <= now()`,
+		userID, key,
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO idempotency_keys (user_id, key, fingerprint, expires_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_id, key) DO NOTHING`,
+		userID, key, fingerprint, time.Now().Add(idempotencyKeyTTL),
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return "", false, err
+	}
+	if claimed == 1 {
+		return "", false, nil
+	}
+
+	var storedFingerprint, response string
+	err = tx.QueryRowContext(ctx, `SELECT fingerprint, response FROM idempotency_keys
+	WHERE user_id = $1 AND key = $2`,
+		userID, key,
+	).Scan(&storedFingerprint, &response)
+	if err != nil {
+		return "", false, err
+	}
+
+	if storedFingerprint != fingerprint {
+		return "", false, ErrIdempotencyKeyReused
+	}
+
+	return response, true, nil
+}
+
+// completeIdempotencyKey stores the result of the request that claimed the key
+func completeIdempotencyKey(ctx context.Context, tx *sql.Tx, userID, key, reservationID, response string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE idempotency_keys SET reservation_id = $3, response = $4
+	WHERE user_id = $1 AND key = $2`,
+		userID, key, reservationID, response,
+	)
+
+	return err
+}
+
+// purgeIdempotencyKeys deletes the expired keys every idempotencyPurgeInterval
+func purgeIdempotencyKeys() {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+		_, err := conn.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= now()`)
+		cancel()
+
+		if err != nil {
+			log.Println("Error purging expired idempotency keys: ", err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -9,25 +10,79 @@ import (
 	"os"
 	"strconv"
 	"time"
+	_ "time/tzdata"
+
+	loggerpb "proto/logger"
+	"proto/rpcclient"
+	"proto/telemetry"
 
 	_ "github.com/lib/pq"
 )
 
-const rpcPort = "5002"
+// gRPC is served on grpcPort. The gob endpoint on rpcPort is only started with
+// GOB_RPC_ENABLED=true, for callers that have not moved to gRPC yet.
+const (
+	grpcPort = "50002"
+	rpcPort  = "5002"
+)
+
+// shutdownTimeout is how long the spans left get to be sent on shutdown
+const shutdownTimeout = 15 * time.Second
 
 var maxAcceptError int
 
 var conn *sql.DB
 
+// loggerClient sends log entries to the logger service
+var loggerClient loggerpb.LoggerServiceClient
+
 func main() {
+	shutdownTracing, err := telemetry.Setup(context.Background(), "reservation-svc")
+	if err != nil {
+		log.Fatal("Error setting up tracing: ", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Println("Error flushing traces: ", err)
+		}
+	}()
+
 	maxAcceptErrorStr := os.Getenv("MAX_ACCEPT_ERROR")
 
-	var err error
 	maxAcceptError, err = strconv.Atoi(maxAcceptErrorStr)
 	if err != nil {
 		log.Fatalf("Error converting MAX_ACCEPT_ERROR to integer: %v", err)
 	}
 
+	if policy := os.Getenv("ACCOUNT_DELETION_RESERVATIONS"); policy != "" {
+		if !validDeletionPolicy(policy) {
+			log.Fatalf("Invalid ACCOUNT_DELETION_RESERVATIONS: %q, want cancel, keep or reject", policy)
+		}
+		deletionPolicy = policy
+	}
+
+	if ttl := os.Getenv("IDEMPOTENCY_KEY_TTL"); ttl != "" {
+		idempotencyKeyTTL, err = time.ParseDuration(ttl)
+		if err != nil || idempotencyKeyTTL <= 0 {
+			log.Fatalf("Invalid IDEMPOTENCY_KEY_TTL: %q", ttl)
+		}
+	}
+
+	rpcServerTLS, rpcClientTLS, err = loadRPCTLS(os.Getenv("RPC_ALLOWED_CLIENTS"))
+	if err != nil {
+		log.Fatal("Error loading RPC certificates: ", err)
+	}
+
+	loggerRPC, err := rpcclient.New(rpcclient.Config{Addr: "logger-svc:50001", TLS: rpcClientTLS})
+	if err != nil {
+		log.Fatal("Error setting up logger gRPC client: ", err)
+	}
+	defer loggerRPC.Close()
+	loggerClient = loggerpb.NewLoggerServiceClient(loggerRPC)
+
 	conn = connectToPostgres()
 	if conn == nil {
 		log.Fatal("Can't connect to Postgres")
@@ -35,13 +90,25 @@ func main() {
 
 	defer conn.Close()
 
-	err = rpc.Register(new(RPCServer))
-	if err != nil {
-		log.Panic("Error registering Rservation RPC server: ", err)
+	go purgeIdempotencyKeys()
+
+	if os.Getenv("GOB_RPC_ENABLED") == "true" {
+		err = rpc.Register(new(RPCServer))
+		if err != nil {
+			log.Panic("Error registering Rservation RPC server: ", err)
+		}
+
+		go func() {
+			if err := rpcListen(); err != nil {
+				log.Panic("Rservation RPC server exited with error: ", err)
+			}
+		}()
 	}
 
-	if err := rpcListen(); err != nil {
-		log.Panic("Rservation RPC server exited with error: ", err)
+	// grpcListen returns once the calls in flight are done after SIGINT or SIGTERM, so
+	// the logger client and the database are closed after them
+	if err := grpcListen(); err != nil {
+		log.Panic("Reservation gRPC server exited with error: ", err)
 	}
 }
 
@@ -74,7 +141,7 @@ func rpcListen() error {
 
 		// reset error count on successful accept
 		acceptFailures = 0
-		go rpc.ServeConn(conn)
+		go serveRPCConn(conn)
 	}
 }
 
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type RestaurantData struct {
+	RestaurantID  string         `json:"id,omitempty"`
+	Name          string         `json:"name"`
+	Address       string         `json:"address,omitempty"`
+	Timezone      string         `json:"timezone,omitempty"`
+	Capacity      int            `json:"capacity"`
+	Tables        []TableData    `json:"tables"`
+	OpeningHours  []OpeningHours `json:"openingHours"`
+	BlackoutDates []BlackoutDate `json:"blackoutDates"`
+	CreatedAt     time.Time      `json:"createdAt,omitempty"`
+}
+
+type TableData struct {
+	TableID string `json:"id,omitempty"`
+	Label   string `json:"label"`
+	Seats   int    `json:"seats"`
+}
+
+// OpeningHours is one opening interval on a weekday (0 = Sunday) in the restaurant's
+// local time, formatted as 15:04. Closes may be 24:00 for midnight.
+type OpeningHours struct {
+	Weekday int    `json:"weekday"`
+	Opens   string `json:"opens"`
+	Closes  string `json:"closes"`
+}
+
+// BlackoutDate is a local date (2006-01-02) on which the restaurant takes no bookings
+type BlackoutDate struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type RestaurantPayload struct {
+	Caller         Caller
+	RestaurantData RestaurantData
+	Page           int
+	PageSize       int
+	RequestID      string
+}
+
+// RestaurantList is the reply of ListRestaurants
+type RestaurantList struct {
+	Restaurants []RestaurantData `json:"restaurants"`
+	Page        int              `json:"page"`
+	PageSize    int              `json:"pageSize"`
+	Total       int              `json:"total"`
+}
+
+const clockLayout = "15:04"
+
+// endOfDay is the closing time of a restaurant open until midnight, as Postgres
+// writes it for a TIME column
+const endOfDay = "24:00"
+const dateLayout = "2006-01-02"
+
+var (
+	ErrRestaurantNotFound     = errors.New("restaurant not found")
+	ErrInvalidReservationTime = errors.New("invalid reservation time")
+	ErrReservationInPast      = errors.New("reservation time is in the past")
+	ErrOutsideOpeningHours    = errors.New("reservation time is outside opening hours")
+	ErrRestaurantClosed       = errors.New("restaurant is closed on that date")
+	ErrInvalidRestaurant      = errors.New("invalid restaurant")
+)
+
+// invalidRestaurant builds the validation error returned by CreateRestaurant
+func invalidRestaurant(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRestaurant, fmt.Sprintf(format, args...))
+}
+
+// CreateRestaurant inserts a restaurant together with its tables, opening hours and
+// blackout dates, and returns the ID of the new restaurant. Only admins can add
+// restaurants.
+func (r *RPCServer) CreateRestaurant(payload RestaurantPayload, resp *string) error {
+	if payload.Caller.Role != roleAdmin {
+		return ErrForbidden
+	}
+
+	rd := payload.RestaurantData
+
+	if err := validateRestaurant(&rd); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("Error starting restaurant transaction via RPC: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var newID string
+	err = tx.QueryRowContext(ctx, `INSERT INTO restaurants (name, address, timezone)
+	VALUES ($1, $2, $3) RETURNING id`,
+		rd.Name,
+		rd.Address,
+		rd.Timezone,
+	).Scan(&newID)
+	if err != nil {
+		log.Println("Error inserting into restaurants via RPC: ", err)
+		return err
+	}
+
+	for _, t := range rd.Tables {
+		_, err = tx.ExecContext(ctx, `INSERT INTO restaurant_tables (restaurant_id, label, seats)
+		VALUES ($1, $2, $3)`, newID, t.Label, t.Seats)
+		if err != nil {
+			log.Println("Error inserting into restaurant_tables via RPC: ", err)
+			return err
+		}
+	}
+
+	for _, h := range rd.OpeningHours {
+		_, err = tx.ExecContext(ctx, `INSERT INTO restaurant_opening_hours (restaurant_id, weekday, opens_at, closes_at)
+		VALUES ($1, $2, $3, $4)`, newID, h.Weekday, h.Opens, h.Closes)
+		if err != nil {
+			log.Println("Error inserting into restaurant_opening_hours via RPC: ", err)
+			return err
+		}
+	}
+
+	for _, b := range rd.BlackoutDates {
+		_, err = tx.ExecContext(ctx, `INSERT INTO restaurant_blackout_dates (restaurant_id, blackout_date, reason)
+		VALUES ($1, $2, $3)`, newID, b.Date, b.Reason)
+		if err != nil {
+			log.Println("Error inserting into restaurant_blackout_dates via RPC: ", err)
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing restaurant via RPC: ", err)
+		return err
+	}
+
+	successMsg := fmt.Sprintf("Restaurant: %s successfully created", newID)
+
+	log.Println(successMsg)
+
+	logItemViaRPC(LogPayload{
+		Name:      "Restaurant_Created",
+		Data:      successMsg,
+		RequestID: payload.RequestID,
+	})
+
+	*resp = newID
+	return nil
+}
+
+// ListRestaurants returns a page of restaurants with their tables, opening hours and
+// upcoming blackout dates
+func (r *RPCServer) ListRestaurants(payload RestaurantPayload, resp *RestaurantList) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	page, pageSize := normalizePaging(payload.Page, payload.PageSize)
+
+	var total int
+	err := conn.QueryRowContext(ctx, `SELECT count(*) FROM restaurants`).Scan(&total)
+	if err != nil {
+		log.Println("Error counting restaurants via RPC: ", err)
+		return err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT id, name, COALESCE(address, ''), timezone, created_at
+	FROM restaurants
+	ORDER BY id
+	LIMIT $1 OFFSET $2`, pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Println("Error listing restaurants via RPC: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	restaurants := []RestaurantData{}
+	index := map[string]int{}
+	ids := []string{}
+
+	for rows.Next() {
+		rd := RestaurantData{
+			Tables:        []TableData{},
+			OpeningHours:  []OpeningHours{},
+			BlackoutDates: []BlackoutDate{},
+		}
+
+		err = rows.Scan(&rd.RestaurantID, &rd.Name, &rd.Address, &rd.Timezone, &rd.CreatedAt)
+		if err != nil {
+			log.Println("Error scanning restaurant via RPC: ", err)
+			return err
+		}
+
+		index[rd.RestaurantID] = len(restaurants)
+		ids = append(ids, rd.RestaurantID)
+		restaurants = append(restaurants, rd)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Println("Error iterating restaurants via RPC: ", err)
+		return err
+	}
+
+	if len(ids) > 0 {
+		if err = loadRestaurantDetails(ctx, ids, index, restaurants); err != nil {
+			log.Println("Error loading restaurant details via RPC: ", err)
+			return err
+		}
+	}
+
+	*resp = RestaurantList{
+		Restaurants: restaurants,
+		Page:        page,
+		PageSize:    pageSize,
+		Total:       total,
+	}
+	return nil
+}
+
+// loadRestaurantDetails fills in tables, opening hours and blackout dates for the
+// restaurants in ids
+func loadRestaurantDetails(ctx context.Context, ids []string, index map[string]int, restaurants []RestaurantData) error {
+	rows, err := conn.QueryContext(ctx, `SELECT restaurant_id, id, label, seats
+	FROM restaurant_tables WHERE restaurant_id = ANY($1::INT[]) ORDER BY id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var restaurantID string
+		var t TableData
+		if err = rows.Scan(&restaurantID, &t.TableID, &t.Label, &t.Seats); err != nil {
+			rows.Close()
+			return err
+		}
+		rd := &restaurants[index[restaurantID]]
+		rd.Tables = append(rd.Tables, t)
+		rd.Capacity += t.Seats
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = conn.QueryContext(ctx, `SELECT restaurant_id, weekday,
+		to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI')
+	FROM restaurant_opening_hours WHERE restaurant_id = ANY($1::INT[]) ORDER BY weekday, opens_at`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var restaurantID string
+		var h OpeningHours
+		if err = rows.Scan(&restaurantID, &h.Weekday, &h.Opens, &h.Closes); err != nil {
+			rows.Close()
+			return err
+		}
+		rd := &restaurants[index[restaurantID]]
+		rd.OpeningHours = append(rd.OpeningHours, h)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = conn.QueryContext(ctx, `SELECT restaurant_id, to_char(blackout_date, 'YYYY-MM-DD'), COALESCE(reason, '')
+	FROM restaurant_blackout_dates
+	WHERE restaurant_id = ANY($1::INT[]) AND blackout_date >= CURRENT_DATE
+	ORDER BY blackout_date`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var restaurantID string
+		var b BlackoutDate
+		if err = rows.Scan(&restaurantID, &b.Date, &b.Reason); err != nil {
+			return err
+		}
+		rd := &restaurants[index[restaurantID]]
+		rd.BlackoutDates = append(rd.BlackoutDates, b)
+	}
+
+	return rows.Err()
+}
+
+// validateRestaurant checks a restaurant before it is created and fills in defaults
+func validateRestaurant(rd *RestaurantData) error {
+	rd.Name = strings.TrimSpace(rd.Name)
+	if rd.Name == "" {
+		return invalidRestaurant("name is required")
+	}
+
+	if rd.Timezone == "" {
+		rd.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(rd.Timezone); err != nil {
+		return invalidRestaurant("unknown timezone %q", rd.Timezone)
+	}
+
+	if len(rd.Tables) == 0 {
+		return invalidRestaurant("at least one table is required")
+	}
+	for _, t := range rd.Tables {
+		if strings.TrimSpace(t.Label) == "" {
+			return invalidRestaurant("table label is required")
+		}
+		if t.Seats < 1 {
+			return invalidRestaurant("table %s must have at least one seat", t.Label)
+		}
+	}
+
+	for _, h := range rd.OpeningHours {
+		if h.Weekday < 0 || h.Weekday > 6 {
+			return invalidRestaurant("weekday must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		opens, err := time.Parse(clockLayout, h.Opens)
+		if err != nil {
+			return invalidRestaurant("opening time %q must be formatted as HH:MM", h.Opens)
+		}
+		closes, err := atClock(opens, h.Closes)
+		if err != nil {
+			return invalidRestaurant("closing time %q must be formatted as HH:MM", h.Closes)
+		}
+		if !opens.Before(closes) {
+			return invalidRestaurant("opening time must be before closing time on weekday %d", h.Weekday)
+		}
+	}
+
+	for _, b := range rd.BlackoutDates {
+		if _, err := time.Parse(dateLayout, b.Date); err != nil {
+			return invalidRestaurant("blackout date %q must be formatted as YYYY-MM-DD", b.Date)
+		}
+	}
+
+	return nil
+}
+
+// checkRestaurantOpen makes sure the restaurant exists and takes bookings at the given
+// future time, which means a reservation starting then ends by closing time. It
+// returns the time unchanged so callers can chain it.
+func checkRestaurantOpen(ctx context.Context, restaurantID string, at time.Time) (time.Time, error) {
+	if at.IsZero() {
+		return time.Time{}, ErrInvalidReservationTime
+	}
+
+	if at.Before(time.Now()) {
+		return time.Time{}, ErrReservationInPast
+	}
+
+	loc, err := restaurantLocation(ctx, restaurantID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	local := at.In(loc)
+
+	var closed bool
+	err = conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM restaurant_blackout_dates WHERE restaurant_id = $1 AND blackout_date = $2
+	)`, restaurantID, local.Format(dateLayout)).Scan(&closed)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if closed {
+		return time.Time{}, ErrRestaurantClosed
+	}
+
+	// closes_at - $3 is the time left until closing; adding the dining duration to $3
+	// instead would wrap around at midnight, and closes_at may be 24:00
+	var open bool
+	err = conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM restaurant_opening_hours
+		WHERE restaurant_id = $1 AND weekday = $2 AND opens_at <= $3::TIME
+		AND closes_at - $3::TIME >= make_interval(mins => $4)
+	)`, restaurantID, int(local.Weekday()), local.Format("15:04:05"), int(diningDuration/time.Minute)).Scan(&open)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !open {
+		return time.Time{}, ErrOutsideOpeningHours
+	}
+
+	return at, nil
+}
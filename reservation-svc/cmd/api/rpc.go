@@ -2,10 +2,17 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"net/rpc"
 	"time"
+
+	loggerpb "proto/logger"
+	"proto/telemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RPCServer struct{}
@@ -14,69 +21,523 @@ type ReservationData struct {
 	ReservationID   string    `json:"id,omitempty"`
 	RestaurantID    string    `json:"restaurantID,omitempty"`
 	UserId          string    `json:"userID,omitempty"`
-	Count           string    `json:"count,omitempty"`
-	ReservationTime string    `json:"reservationTime,omitempty"`
+	Count           int       `json:"count,omitempty"`
+	ReservationTime time.Time `json:"reservationTime,omitempty"`
 	Remarks         string    `json:"remarks,omitempty"`
+	Status          string    `json:"status,omitempty"`
 	CreatedAt       time.Time `json:"createdAt,omitempty"`
 }
 
 type RPCPayload struct {
+	Caller          Caller
 	ReservationData ReservationData
+	Page            int
+	PageSize        int
+	IdempotencyKey  string
+
+	// RequestID is the ID the broker gave the request, stored with the log entries
+	RequestID string
+
+	// TraceContext is the caller's W3C trace context, such as its traceparent
+	TraceContext map[string]string
+}
+
+// ReservationList is the reply of ListReservations
+type ReservationList struct {
+	Reservations []ReservationData `json:"reservations"`
+	Page         int               `json:"page"`
+	PageSize     int               `json:"pageSize"`
+	Total        int               `json:"total"`
 }
 
 type LogPayload struct {
 	Name string `json:"name"`
 	Data string `json:"data"`
+
+	RequestID string `json:"requestId,omitempty"`
+
+	// TraceContext carries the trace of the call that logs the entry on to the logger
+	// service
+	TraceContext map[string]string `json:"traceContext,omitempty"`
 }
 
 const dbTimeout = time.Second * 3
 
+var tracer = otel.Tracer("reservation")
+
+// paging defaults for ListReservations
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// reservation statuses
+const (
+	statusConfirmed = "confirmed"
+	statusCancelled = "cancelled"
+)
+
+var (
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrReservationCancelled = errors.New("reservation already cancelled")
+)
+
+const reservationColumns = `id, COALESCE(restaurant_id, ''), user_id, count,
+	reservation_time, COALESCE(remarks, ''), status, created_at`
+
+// CreateReservation books a table for the party. The table is assigned inside a
+// transaction that locks the restaurant's tables, so concurrent bookings cannot take
+// the same table at overlapping times.
 func (r *RPCServer) CreateReservation(payload RPCPayload, resp *string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	ctx, span := tracer.Start(telemetry.Extract(context.Background(), payload.TraceContext), "RPCServer.CreateReservation",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	message, _, err := createReservation(ctx, payload)
+	telemetry.End(span, err)
+	if err != nil {
+		return err
+	}
+
+	*resp = message
+	return nil
+}
+
+// createReservation books the reservation of CreateReservation. With an idempotency
+// key, a request already made with the key is not booked again: its response is
+// returned with replayed set. The booking goes through even if the caller gives up on
+// it; ctx only carries the caller's trace.
+func createReservation(ctx context.Context, payload RPCPayload) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dbTimeout)
 	defer cancel()
 
+	rd := payload.ReservationData
+	key := payload.IdempotencyKey
+
+	if rd.Count < 1 {
+		return "", false, ErrInvalidPartySize
+	}
+	if key != "" && !validIdempotencyKey(key) {
+		return "", false, ErrInvalidIdempotencyKey
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("Error starting reservation transaction via RPC: ", err)
+		return "", false, err
+	}
+	defer tx.Rollback()
+
+	// the key is claimed before the checks, which may no longer pass when a request
+	// that succeeded is replayed
+	if key != "" {
+		spanCtx, span := tracer.Start(ctx, "claimIdempotencyKey")
+		response, replayed, err := claimIdempotencyKey(spanCtx, tx, rd.UserId, key, reservationFingerprint(rd))
+		telemetry.End(span, err)
+		if err != nil {
+			if !errors.Is(err, ErrIdempotencyKeyReused) {
+				log.Println("Error claiming idempotency key via RPC: ", err)
+			}
+			return "", false, err
+		}
+		if replayed {
+			return response, true, nil
+		}
+	}
+
+	spanCtx, span := tracer.Start(ctx, "checkRestaurantOpen")
+	at, err := checkRestaurantOpen(spanCtx, rd.RestaurantID, rd.ReservationTime)
+	telemetry.End(span, err)
+	if err != nil {
+		log.Println("Rejected reservation via RPC: ", err)
+		return "", false, err
+	}
+
+	spanCtx, span = tracer.Start(ctx, "assignTable")
+	tableID, err := assignTable(spanCtx, tx, rd.RestaurantID, at, rd.Count, "")
+	telemetry.End(span, err)
+	if err != nil {
+		log.Println("Error assigning table via RPC: ", err)
+		return "", false, err
+	}
+
 	var newID string
 
-	stmt := `INSERT INTO reservations (restaurant_id, user_id, count, reservation_time, remarks)
-	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	stmt := `INSERT INTO reservations (restaurant_id, user_id, count, reservation_time, remarks, table_id)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
 
-	err := conn.QueryRowContext(ctx, stmt,
-		payload.ReservationData.RestaurantID,
-		payload.ReservationData.UserId,
-		payload.ReservationData.Count,
-		payload.ReservationData.ReservationTime,
-		payload.ReservationData.Remarks,
+	spanCtx, span = telemetry.StartQuery(ctx, tracer, "INSERT", "reservations", stmt)
+	err = tx.QueryRowContext(spanCtx, stmt,
+		rd.RestaurantID,
+		rd.UserId,
+		rd.Count,
+		at,
+		rd.Remarks,
+		tableID,
 	).Scan(&newID)
+	telemetry.End(span, err)
 	if err != nil {
 		log.Println("Error inserting into reservations via RPC: ", err)
-		return err
+		return "", false, err
 	}
 
-	successMsg := fmt.Sprintf("Reservation: %s successfully created for userID: %s", newID, payload.ReservationData.UserId)
+	message := "Reservation created successfully"
+
+	if key != "" {
+		if err = completeIdempotencyKey(ctx, tx, rd.UserId, key, newID, message); err != nil {
+			log.Println("Error storing idempotency key via RPC: ", err)
+			return "", false, err
+		}
+	}
+
+	_, span = tracer.Start(ctx, "COMMIT")
+	err = tx.Commit()
+	telemetry.End(span, err)
+	if err != nil {
+		log.Println("Error committing reservation via RPC: ", err)
+		return "", false, err
+	}
+
+	successMsg := fmt.Sprintf("Reservation: %s successfully created for userID: %s", newID, rd.UserId)
 
 	log.Println(successMsg)
 
 	var logPayload LogPayload
 	logPayload.Name = "Reservation_Created"
 	logPayload.Data = successMsg
+	logPayload.RequestID = payload.RequestID
+	logPayload.TraceContext = telemetry.Inject(ctx)
 
 	logItemViaRPC(logPayload)
 
-	*resp = "Reservation created successfully"
+	return message, false, nil
+}
+
+// GetReservation returns one reservation the caller may see
+func (r *RPCServer) GetReservation(payload RPCPayload, resp *ReservationData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	scope, scopeArgs := reservationScope(payload.Caller, 2)
+	query := `SELECT ` + reservationColumns + ` FROM reservations WHERE id = $1 AND ` + scope
+
+	rd, err := scanReservation(conn.QueryRowContext(ctx, query,
+		append([]any{payload.ReservationData.ReservationID}, scopeArgs...)...,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReservationNotFound
+		}
+		log.Println("Error getting reservation via RPC: ", err)
+		return err
+	}
+
+	*resp = *rd
 	return nil
 }
 
-func logItemViaRPC(l LogPayload) {
-	client, err := rpc.Dial("tcp", "logger-svc:5001")
+// ListReservations returns a page of the caller's own reservations or, with
+// payload.ReservationData.RestaurantID set, of all reservations at a restaurant the
+// caller manages
+func (r *RPCServer) ListReservations(payload RPCPayload, resp *ReservationList) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	page, pageSize := normalizePaging(payload.Page, payload.PageSize)
+
+	filter := `user_id = $1`
+	filterArg := payload.Caller.UserID
+
+	if restaurantID := payload.ReservationData.RestaurantID; restaurantID != "" {
+		ok, err := canManageRestaurant(ctx, payload.Caller, restaurantID)
+		if err != nil {
+			log.Println("Error checking restaurant staff via RPC: ", err)
+			return err
+		}
+		if !ok {
+			return ErrForbidden
+		}
+
+		filter = `restaurant_id = $1`
+		filterArg = restaurantID
+	}
+
+	var total int
+	err := conn.QueryRowContext(ctx, `SELECT count(*) FROM reservations WHERE `+filter,
+		filterArg,
+	).Scan(&total)
 	if err != nil {
-		log.Println("Error connecting to logger rpc from reservation: ", err)
+		log.Println("Error counting reservations via RPC: ", err)
+		return err
 	}
 
-	var result string
-	err = client.Call("RPCServer.LogInfoViaRPC", l, &result)
+	query := `SELECT ` + reservationColumns + ` FROM reservations
+	WHERE ` + filter + `
+	ORDER BY reservation_time DESC, id DESC
+	LIMIT $2 OFFSET $3`
+
+	rows, err := conn.QueryContext(ctx, query,
+		filterArg,
+		pageSize,
+		(page-1)*pageSize,
+	)
+	if err != nil {
+		log.Println("Error listing reservations via RPC: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	reservations := []ReservationData{}
+	for rows.Next() {
+		rd, err := scanReservation(rows)
+		if err != nil {
+			log.Println("Error scanning reservation via RPC: ", err)
+			return err
+		}
+		reservations = append(reservations, *rd)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Println("Error iterating reservations via RPC: ", err)
+		return err
+	}
+
+	*resp = ReservationList{
+		Reservations: reservations,
+		Page:         page,
+		PageSize:     pageSize,
+		Total:        total,
+	}
+	return nil
+}
+
+// UpdateReservation changes the count, time and remarks of a reservation. Zero fields
+// are left untouched. A new count or time re-assigns the table.
+func (r *RPCServer) UpdateReservation(payload RPCPayload, resp *string) error {
+	ctx, cancel := context.WithTimeout(telemetry.Extract(context.Background(), payload.TraceContext), dbTimeout)
+	defer cancel()
+
+	rd := payload.ReservationData
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("Error starting reservation transaction via RPC: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	scope, scopeArgs := reservationScope(payload.Caller, 2)
+
+	var current ReservationData
+	var currentTime time.Time
+	var tableID sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(restaurant_id, ''), count, reservation_time, status, table_id
+	FROM reservations WHERE id = $1 AND `+scope+` FOR UPDATE`,
+		append([]any{rd.ReservationID}, scopeArgs...)...,
+	).Scan(&current.RestaurantID, &current.Count, &currentTime, &current.Status, &tableID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReservationNotFound
+		}
+		log.Println("Error getting reservation for update via RPC: ", err)
+		return err
+	}
+
+	if current.Status == statusCancelled {
+		return ErrReservationCancelled
+	}
+
+	count := current.Count
+	at := currentTime
+
+	if rd.Count != 0 {
+		count = rd.Count
+	}
+
+	if !rd.ReservationTime.IsZero() {
+		at, err = checkRestaurantOpen(ctx, current.RestaurantID, rd.ReservationTime)
+		if err != nil {
+			log.Println("Rejected reservation update via RPC: ", err)
+			return err
+		}
+	}
+
+	if count != current.Count || !at.Equal(currentTime) {
+		if count < 1 {
+			return ErrInvalidPartySize
+		}
+
+		newTable, err := assignTable(ctx, tx, current.RestaurantID, at, count, rd.ReservationID)
+		if err != nil {
+			log.Println("Error assigning table for update via RPC: ", err)
+			return err
+		}
+		tableID = sql.NullString{String: newTable, Valid: true}
+	}
+
+	stmt := `UPDATE reservations SET
+		count = $2,
+		reservation_time = $3,
+		remarks = COALESCE(NULLIF($4, ''), remarks),
+		table_id = $5,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1`
+
+	_, err = tx.ExecContext(ctx, stmt,
+		rd.ReservationID,
+		count,
+		at,
+		rd.Remarks,
+		tableID,
+	)
+	if err != nil {
+		log.Println("Error updating reservation via RPC: ", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing reservation update via RPC: ", err)
+		return err
+	}
+
+	successMsg := fmt.Sprintf("Reservation: %s successfully updated by userID: %s", rd.ReservationID, payload.Caller.UserID)
+
+	log.Println(successMsg)
+
+	logItemViaRPC(LogPayload{
+		Name:         "Reservation_Updated",
+		Data:         successMsg,
+		RequestID:    payload.RequestID,
+		TraceContext: telemetry.Inject(ctx),
+	})
+
+	*resp = "Reservation updated successfully"
+	return nil
+}
+
+// CancelReservation marks a reservation as cancelled. The row is kept for history.
+func (r *RPCServer) CancelReservation(payload RPCPayload, resp *string) error {
+	ctx, cancel := context.WithTimeout(telemetry.Extract(context.Background(), payload.TraceContext), dbTimeout)
+	defer cancel()
+
+	rd := payload.ReservationData
+
+	scope, scopeArgs := reservationScope(payload.Caller, 3)
+
+	stmt := `UPDATE reservations SET
+		status = $2,
+		cancelled_at = CURRENT_TIMESTAMP,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status <> $2 AND ` + scope
+
+	result, err := conn.ExecContext(ctx, stmt, append([]any{rd.ReservationID, statusCancelled}, scopeArgs...)...)
+	if err != nil {
+		log.Println("Error cancelling reservation via RPC: ", err)
+		return err
+	}
+
+	if err = ensureAffected(ctx, result, rd.ReservationID, payload.Caller); err != nil {
+		return err
+	}
+
+	successMsg := fmt.Sprintf("Reservation: %s cancelled by userID: %s", rd.ReservationID, payload.Caller.UserID)
+
+	log.Println(successMsg)
+
+	logItemViaRPC(LogPayload{
+		Name:         "Reservation_Cancelled",
+		Data:         successMsg,
+		RequestID:    payload.RequestID,
+		TraceContext: telemetry.Inject(ctx),
+	})
+
+	*resp = "Reservation cancelled successfully"
+	return nil
+}
+
+// ensureAffected tells apart a missing reservation from a cancelled one when an
+// update matched no rows.
+func ensureAffected(ctx context.Context, result sql.Result, reservationID string, c Caller) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		return nil
+	}
+
+	scope, scopeArgs := reservationScope(c, 2)
+
+	var status string
+	err = conn.QueryRowContext(ctx, `SELECT status FROM reservations WHERE id = $1 AND `+scope,
+		append([]any{reservationID}, scopeArgs...)...,
+	).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReservationNotFound
+		}
+		return err
+	}
+
+	if status == statusCancelled {
+		return ErrReservationCancelled
+	}
+
+	return ErrReservationNotFound
+}
+
+// normalizePaging applies the default and maximum page size to a page request
+func normalizePaging(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	} else if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReservation(row rowScanner) (*ReservationData, error) {
+	var rd ReservationData
+	var reservationTime sql.NullTime
+
+	err := row.Scan(
+		&rd.ReservationID,
+		&rd.RestaurantID,
+		&rd.UserId,
+		&rd.Count,
+		&reservationTime,
+		&rd.Remarks,
+		&rd.Status,
+		&rd.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if reservationTime.Valid {
+		rd.ReservationTime = reservationTime.Time
+	}
+
+	return &rd, nil
+}
+
+func logItemViaRPC(l LogPayload) {
+	ctx := telemetry.Extract(context.Background(), l.TraceContext)
+
+	reply, err := loggerClient.Log(ctx, &loggerpb.LogRequest{Name: l.Name, Data: l.Data, RequestId: l.RequestID})
 	if err != nil {
 		log.Println("Error sending payload to logger rpc from reservation: ", err)
+		return
 	}
 
-	log.Println(result)
+	log.Println(reply.GetMessage())
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+	"time"
+)
+
+// The RPC ports, gRPC and gob alike, only speak mutual TLS: callers present a
+// certificate signed by the services' CA (see project/gen-certs.sh), and only the
+// services listed in RPC_ALLOWED_CLIENTS are served.
+const rpcHandshakeTimeout = 5 * time.Second
+
+var (
+	// rpcServerTLS is used to serve RPC, rpcClientTLS to call the logger service
+	rpcServerTLS *tls.Config
+	rpcClientTLS *tls.Config
+)
+
+// loadRPCTLS reads the certificate, key and CA named by RPC_TLS_CERT, RPC_TLS_KEY and
+// RPC_TLS_CA. allowedClients is a comma separated list of the service names that may
+// call this service; if empty, any certificate from the CA is accepted.
+func loadRPCTLS(allowedClients string) (server *tls.Config, client *tls.Config, err error) {
+	certFile, keyFile, caFile := os.Getenv("RPC_TLS_CERT"), os.Getenv("RPC_TLS_KEY"), os.Getenv("RPC_TLS_CA")
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, nil, errors.New("RPC_TLS_CERT, RPC_TLS_KEY and RPC_TLS_CA are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(allowedClients, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+
+	server = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(allowed) == 0 {
+				return nil
+			}
+			if name := peerName(cs.PeerCertificates[0]); !allowed[name] {
+				return fmt.Errorf("client %q is not allowed", name)
+			}
+			return nil
+		},
+	}
+
+	client = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS13,
+	}
+
+	return server, client, nil
+}
+
+// peerName is the service name a certificate was issued to
+func peerName(cert *x509.Certificate) string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
+
+// serveRPCConn completes the TLS handshake, which checks the client's certificate,
+// before serving RPC on the connection
+func serveRPCConn(conn net.Conn) {
+	tlsConn := tls.Server(conn, rpcServerTLS)
+
+	_ = tlsConn.SetDeadline(time.Now().Add(rpcHandshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		log.Printf("Rejected RPC connection from %v: %v\n", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+
+	rpc.ServeConn(tlsConn)
+}
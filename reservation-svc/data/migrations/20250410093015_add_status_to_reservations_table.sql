@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE reservations
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'confirmed',
+    ADD COLUMN updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN cancelled_at TIMESTAMPTZ;
+
+CREATE INDEX idx_reservations_user_id ON reservations (user_id, reservation_time DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_reservations_user_id;
+
+ALTER TABLE reservations
+    DROP COLUMN cancelled_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN status;
+-- +goose StatementEnd
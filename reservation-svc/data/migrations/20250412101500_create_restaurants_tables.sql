@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE restaurants (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    address TEXT,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE restaurant_tables (
+    id SERIAL PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    label VARCHAR(50) NOT NULL,
+    seats INT NOT NULL CHECK (seats > 0),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    UNIQUE (restaurant_id, label)
+);
+
+CREATE TABLE restaurant_opening_hours (
+    id SERIAL PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_at TIME NOT NULL,
+    closes_at TIME NOT NULL,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    CHECK (opens_at < closes_at)
+);
+
+CREATE INDEX idx_restaurant_opening_hours_weekday ON restaurant_opening_hours (restaurant_id, weekday);
+
+CREATE TABLE restaurant_blackout_dates (
+    id SERIAL PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    blackout_date DATE NOT NULL,
+    reason TEXT,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    UNIQUE (restaurant_id, blackout_date)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE restaurant_blackout_dates;
+DROP TABLE restaurant_opening_hours;
+DROP TABLE restaurant_tables;
+DROP TABLE restaurants;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE reservations
+    ADD COLUMN table_id INT REFERENCES restaurant_tables(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_reservations_table_time ON reservations (table_id, reservation_time)
+    WHERE status <> 'cancelled';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_reservations_table_time;
+
+ALTER TABLE reservations
+    DROP COLUMN table_id;
+-- +goose StatementEnd
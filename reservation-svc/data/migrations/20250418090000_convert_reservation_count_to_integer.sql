@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- counts that are not a positive number are kept here, as they were, before they
+-- are set to 1, so they can be reviewed and fixed by hand
+CREATE TABLE reservation_count_rejects (
+    reservation_id INT PRIMARY KEY,
+    count VARCHAR(50),
+    rejected_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO reservation_count_rejects (reservation_id, count)
+SELECT id, count FROM reservations
+WHERE CASE
+    WHEN trim(count) ~ '^[0-9]+$' THEN trim(count)::NUMERIC NOT BETWEEN 1 AND 2147483647
+    ELSE TRUE
+END;
+
+ALTER TABLE reservations
+    ALTER COLUMN count TYPE INT
+    USING CASE
+        WHEN trim(count) !~ '^[0-9]+$' THEN NULL
+        WHEN trim(count)::NUMERIC BETWEEN 1 AND 2147483647 THEN trim(count)::INT
+    END;
+
+UPDATE reservations SET count = 1
+WHERE id IN (SELECT reservation_id FROM reservation_count_rejects);
+
+ALTER TABLE reservations
+    ALTER COLUMN count SET NOT NULL,
+    ADD CONSTRAINT reservations_count_positive CHECK (count > 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE reservations
+    DROP CONSTRAINT reservations_count_positive,
+    ALTER COLUMN count DROP NOT NULL;
+
+ALTER TABLE reservations
+    ALTER COLUMN count TYPE VARCHAR(50) USING count::VARCHAR;
+
+UPDATE reservations r SET count = rejects.count
+FROM reservation_count_rejects rejects
+WHERE r.id = rejects.reservation_id;
+
+DROP TABLE reservation_count_rejects;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE restaurant_staff (
+    restaurant_id INT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (restaurant_id, user_id)
+);
+
+CREATE INDEX idx_restaurant_staff_user_id ON restaurant_staff (user_id);
+
+CREATE INDEX idx_reservations_restaurant_id ON reservations (restaurant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_reservations_restaurant_id;
+
+DROP TABLE restaurant_staff;
+-- +goose StatementEnd